@@ -0,0 +1,9 @@
+package data
+
+// Batch is a group of points pushed from the reader to storage.
+// ID identifies the batch in the reader's outbox and in logs. Storage doesn't need it for deduplication:
+// points are keyed by address and timestamp, so a batch delivered more than once is stored once.
+type Batch struct {
+	ID     string
+	Points []*Point
+}
@@ -0,0 +1,22 @@
+package data
+
+import (
+	"time"
+)
+
+// Calibration corrects a quantity reported by a tag: corrected = raw * Scale + Offset.
+// It applies to points taken at or after EffectiveFrom, until superseded by a later calibration.
+type Calibration struct {
+	Address string
+	// Kind is the quantity, e.g. temperature or humidity.
+	Kind          string
+	EffectiveFrom time.Time
+
+	Offset float64
+	Scale  float64
+}
+
+// Apply returns the corrected value.
+func (c *Calibration) Apply(v float64) float64 {
+	return v*c.Scale + c.Offset
+}
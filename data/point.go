@@ -10,47 +10,196 @@ import (
 )
 
 // Point represents a single data point from a RuuviTag.
+// Measurement fields are nil if the tag didn't report them or reported them as not available.
 type Point struct {
 	Address   string    `json:",omitempty"`
 	Timestamp time.Time `json:",omitempty"`
 
-	Temperature float64 `json:",omitempty"`
-	Humidity    float64 `json:",omitempty"`
-	Pressure    float64 `json:",omitempty"`
-	Battery     float64 `json:",omitempty"`
+	Temperature *float64 `json:",omitempty"`
+	Humidity    *float64 `json:",omitempty"`
+	Pressure    *float64 `json:",omitempty"`
+	Battery     *float64 `json:",omitempty"`
+
+	AccelerationX       *float64 `json:",omitempty"`
+	AccelerationY       *float64 `json:",omitempty"`
+	AccelerationZ       *float64 `json:",omitempty"`
+	MovementCounter     *uint32  `json:",omitempty"`
+	MeasurementSequence *uint32  `json:",omitempty"`
+
+	// RSSI is the received signal strength in dBm, as seen by the reader.
+	RSSI *int `json:",omitempty"`
+	// TxPower is the transmit power in dBm, as advertised by the tag.
+	TxPower *int `json:",omitempty"`
+
+	// Adapter is the reader's HCI device that received the data point, e.g. hci0.
+	// It's informational only and isn't part of the encoding.
+	Adapter string `json:",omitempty"`
+}
+
+// Ptr returns a pointer to v, for populating optional Point fields.
+func Ptr[T any](v T) *T {
+	return &v
 }
 
 func (d Point) String() string {
-	return fmt.Sprintf("%s @ %v: %.2f °C, %.2f%% humid, %.2f hPa, %.2f mV", d.Address, d.Timestamp.Format(time.DateTime), d.Temperature, d.Humidity, d.Pressure, d.Battery)
+	f := func(v *float64, unit string) string {
+		if v == nil {
+			return "n/a " + unit
+		}
+		return fmt.Sprintf("%.2f %s", *v, unit)
+	}
+	return fmt.Sprintf("%s @ %v: %s, %s humid, %s, %s", d.Address, d.Timestamp.Format(time.DateTime), f(d.Temperature, "°C"), f(d.Humidity, "%"), f(d.Pressure, "hPa"), f(d.Battery, "mV"))
 }
 
+const (
+	// V1 is the original encoding; it has no version byte and is recognized by its length.
+	encodingV1Len = 46
+
+	// V2 starts with a version byte and a bitmask of present fields, and adds motion and radio data.
+	encodingV2    = 2
+	encodingV2Len = 85
+)
+
+// Bits of the V2 presence mask.
+const (
+	hasTemperature = 1 << iota
+	hasHumidity
+	hasPressure
+	hasBattery
+	hasAccelerationX
+	hasAccelerationY
+	hasAccelerationZ
+	hasMovementCounter
+	hasMeasurementSequence
+	hasRSSI
+	hasTxPower
+)
+
 func (d Point) Encode() ([]byte, error) {
 	mac, err := net.ParseMAC(d.Address)
 	if err != nil {
 		return nil, err
 	}
 
-	b := make([]byte, 46)
-	binary.BigEndian.PutUint64(b[0:8], uint64(d.Timestamp.UnixNano()))
-	binary.BigEndian.PutUint64(b[8:16], math.Float64bits(d.Temperature))
-	binary.BigEndian.PutUint64(b[16:24], math.Float64bits(d.Humidity))
-	binary.BigEndian.PutUint64(b[24:32], math.Float64bits(d.Pressure))
-	binary.BigEndian.PutUint64(b[32:40], math.Float64bits(d.Battery))
-	copy(b[40:46], mac)
+	var mask uint16
+	f64 := func(b []byte, v *float64, bit uint16) {
+		if v != nil {
+			mask |= bit
+			binary.BigEndian.PutUint64(b, math.Float64bits(*v))
+		}
+	}
+	u32 := func(b []byte, v *uint32, bit uint16) {
+		if v != nil {
+			mask |= bit
+			binary.BigEndian.PutUint32(b, *v)
+		}
+	}
+	i16 := func(b []byte, v *int, bit uint16) {
+		if v != nil {
+			mask |= bit
+			binary.BigEndian.PutUint16(b, uint16(int16(*v)))
+		}
+	}
+
+	b := make([]byte, encodingV2Len)
+	b[0] = encodingV2
+	binary.BigEndian.PutUint64(b[1:9], uint64(d.Timestamp.UnixNano()))
+	copy(b[9:15], mac)
+	f64(b[17:25], d.Temperature, hasTemperature)
+	f64(b[25:33], d.Humidity, hasHumidity)
+	f64(b[33:41], d.Pressure, hasPressure)
+	f64(b[41:49], d.Battery, hasBattery)
+	f64(b[49:57], d.AccelerationX, hasAccelerationX)
+	f64(b[57:65], d.AccelerationY, hasAccelerationY)
+	f64(b[65:73], d.AccelerationZ, hasAccelerationZ)
+	u32(b[73:77], d.MovementCounter, hasMovementCounter)
+	u32(b[77:81], d.MeasurementSequence, hasMeasurementSequence)
+	i16(b[81:83], d.RSSI, hasRSSI)
+	i16(b[83:85], d.TxPower, hasTxPower)
+	binary.BigEndian.PutUint16(b[15:17], mask)
 
 	return b, nil
 }
 
 func DecodePoint(b []byte) (*Point, error) {
-	if got, want := len(b), 46; got != want {
+	if len(b) == encodingV1Len {
+		return decodePointV1(b)
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("got 0 bytes")
+	}
+
+	switch v := b[0]; v {
+	case encodingV2:
+		return decodePointV2(b)
+	default:
+		return nil, fmt.Errorf("unknown encoding version %d", v)
+	}
+}
+
+// V1 can't tell zero from missing.
+// Temperature, humidity and pressure are assumed present; battery is assumed missing if zero.
+
+func decodePointV1(b []byte) (*Point, error) {
+	if got, want := len(b), encodingV1Len; got != want {
 		return nil, fmt.Errorf("got %d bytes, want %d", got, want)
 	}
 	return &Point{
 		Timestamp:   time.Unix(0, int64(binary.BigEndian.Uint64(b[0:8]))),
-		Temperature: math.Float64frombits(binary.BigEndian.Uint64(b[8:16])),
-		Humidity:    math.Float64frombits(binary.BigEndian.Uint64(b[16:24])),
-		Pressure:    math.Float64frombits(binary.BigEndian.Uint64(b[24:32])),
-		Battery:     math.Float64frombits(binary.BigEndian.Uint64(b[32:40])),
+		Temperature: Ptr(math.Float64frombits(binary.BigEndian.Uint64(b[8:16]))),
+		Humidity:    Ptr(math.Float64frombits(binary.BigEndian.Uint64(b[16:24]))),
+		Pressure:    Ptr(math.Float64frombits(binary.BigEndian.Uint64(b[24:32]))),
+		Battery:     nonZero(math.Float64frombits(binary.BigEndian.Uint64(b[32:40]))),
 		Address:     strings.ToUpper(net.HardwareAddr(b[40:46]).String()),
 	}, nil
 }
+
+func decodePointV2(b []byte) (*Point, error) {
+	if got, want := len(b), encodingV2Len; got != want {
+		return nil, fmt.Errorf("got %d bytes, want %d", got, want)
+	}
+
+	mask := binary.BigEndian.Uint16(b[15:17])
+	f64 := func(b []byte, bit uint16) *float64 {
+		if mask&bit == 0 {
+			return nil
+		}
+		return Ptr(math.Float64frombits(binary.BigEndian.Uint64(b)))
+	}
+	u32 := func(b []byte, bit uint16) *uint32 {
+		if mask&bit == 0 {
+			return nil
+		}
+		return Ptr(binary.BigEndian.Uint32(b))
+	}
+	i16 := func(b []byte, bit uint16) *int {
+		if mask&bit == 0 {
+			return nil
+		}
+		return Ptr(int(int16(binary.BigEndian.Uint16(b))))
+	}
+
+	return &Point{
+		Timestamp:           time.Unix(0, int64(binary.BigEndian.Uint64(b[1:9]))),
+		Address:             strings.ToUpper(net.HardwareAddr(b[9:15]).String()),
+		Temperature:         f64(b[17:25], hasTemperature),
+		Humidity:            f64(b[25:33], hasHumidity),
+		Pressure:            f64(b[33:41], hasPressure),
+		Battery:             f64(b[41:49], hasBattery),
+		AccelerationX:       f64(b[49:57], hasAccelerationX),
+		AccelerationY:       f64(b[57:65], hasAccelerationY),
+		AccelerationZ:       f64(b[65:73], hasAccelerationZ),
+		MovementCounter:     u32(b[73:77], hasMovementCounter),
+		MeasurementSequence: u32(b[77:81], hasMeasurementSequence),
+		RSSI:                i16(b[81:83], hasRSSI),
+		TxPower:             i16(b[83:85], hasTxPower),
+	}, nil
+}
+
+func nonZero[T comparable](v T) *T {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return &v
+}
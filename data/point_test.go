@@ -0,0 +1,134 @@
+package data
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		p    *Point
+	}{
+		{
+			name: "all fields",
+			p: &Point{
+				Address:             "AA:BB:CC:DD:EE:FF",
+				Timestamp:           time.Unix(1700000000, 123),
+				Temperature:         Ptr(21.5),
+				Humidity:            Ptr(45.25),
+				Pressure:            Ptr(1013.25),
+				Battery:             Ptr[float64](2950),
+				AccelerationX:       Ptr(-0.004),
+				AccelerationY:       Ptr(0.012),
+				AccelerationZ:       Ptr(1.036),
+				MovementCounter:     Ptr[uint32](66),
+				MeasurementSequence: Ptr[uint32](205),
+				RSSI:                Ptr(-87),
+				TxPower:             Ptr(-4),
+			},
+		},
+		{
+			name: "zero values",
+			p: &Point{
+				Address:         "AA:BB:CC:DD:EE:FF",
+				Timestamp:       time.Unix(1700000000, 0),
+				Temperature:     Ptr[float64](0),
+				MovementCounter: Ptr[uint32](0),
+				TxPower:         Ptr(0),
+			},
+		},
+		{
+			name: "no fields",
+			p: &Point{
+				Address:   "AA:BB:CC:DD:EE:FF",
+				Timestamp: time.Unix(1700000000, 0),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.p.Encode()
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+
+			got, err := DecodePoint(b)
+			if err != nil {
+				t.Fatalf("DecodePoint failed: %v", err)
+			}
+			if diff := cmp.Diff(tc.p, got); diff != "" {
+				t.Errorf("DecodePoint diff -want +got\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestDecodeLegacy(t *testing.T) {
+	f64 := func(v float64) []byte { return binary.BigEndian.AppendUint64(nil, math.Float64bits(v)) }
+	ts := binary.BigEndian.AppendUint64(nil, uint64(time.Unix(1700000000, 0).UnixNano()))
+	mac := []byte{0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF}
+	cat := func(bs ...[]byte) []byte {
+		var ret []byte
+		for _, b := range bs {
+			ret = append(ret, b...)
+		}
+		return ret
+	}
+
+	for _, tc := range []struct {
+		name string
+		b    []byte
+		want *Point
+	}{
+		{
+			name: "v1",
+			b:    cat(ts, f64(21.5), f64(45.25), f64(1013.25), f64(2950), mac),
+			want: &Point{
+				Address:     "AA:BB:CC:DD:EE:FF",
+				Timestamp:   time.Unix(1700000000, 0),
+				Temperature: Ptr(21.5),
+				Humidity:    Ptr(45.25),
+				Pressure:    Ptr(1013.25),
+				Battery:     Ptr[float64](2950),
+			},
+		},
+		{
+			name: "v1 without battery",
+			b:    cat(ts, f64(0), f64(45.25), f64(1013.25), f64(0), mac),
+			want: &Point{
+				Address:     "AA:BB:CC:DD:EE:FF",
+				Timestamp:   time.Unix(1700000000, 0),
+				Temperature: Ptr[float64](0),
+				Humidity:    Ptr(45.25),
+				Pressure:    Ptr(1013.25),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DecodePoint(tc.b)
+			if err != nil {
+				t.Fatalf("DecodePoint failed: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("DecodePoint diff -want +got\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	for _, b := range [][]byte{
+		nil,
+		{encodingV2, 1, 2, 3},
+		{3, 1, 2, 3},
+		make([]byte, encodingV2Len),
+	} {
+		if _, err := DecodePoint(b); err == nil {
+			t.Errorf("DecodePoint(%X) succeeded, want error", b)
+		}
+	}
+}
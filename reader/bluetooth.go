@@ -4,6 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/s5i/ruuvi2db/data"
@@ -11,24 +15,219 @@ import (
 	"github.com/s5i/ruuvi2db/reader/protocol"
 )
 
+var (
+	ErrAdaptersFailed = fmt.Errorf("all bluetooth adapters failed")
+)
+
+// Replaced in tests.
+var (
+	bluetoothRun   = bluetooth.Run
+	bluetoothReset = bluetooth.Reset
+)
+
 type RunBluetoothOpts struct {
+	// DeviceIDs lists the HCI devices to scan with, e.g. 0 for hci0.
+	DeviceIDs       []int
 	WatchdogTimeout time.Duration
-	CachePointF     func(*data.Point)
+
+	// A failed scan is restarted after a delay that doubles with each consecutive failure,
+	// from RestartBackoffMin up to RestartBackoffMax.
+	RestartBackoffMin time.Duration
+	RestartBackoffMax time.Duration
+	// MaxFailures is the number of consecutive failures after which an adapter is given up on.
+	// Zero means never.
+	MaxFailures int
+
+	Format8Keys map[string][]byte
+	CachePointF func(*data.Point)
+	TagTracker  *TagTracker
+	States      *AdapterStates
 }
 
+// RunBluetooth runs a supervised scan loop per adapter. A failing adapter is reset and restarted
+// on its own, so that the others keep going. It only returns an error once all adapters have been given up on.
 func RunBluetooth(ctx context.Context, opts *RunBluetoothOpts) error {
-	switch err := bluetooth.Run(ctx, func(addr string, mfID uint16, datagram []byte) {
-		p, err := protocol.ParseDatagram(mfID, datagram, addr)
-		if err != nil {
-			return
-		}
-		opts.CachePointF(p)
-	}, opts.WatchdogTimeout); {
-	case errors.Is(err, context.Canceled):
+	states := opts.States
+	if states == nil {
+		states = &AdapterStates{}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(opts.DeviceIDs))
+	for i, id := range opts.DeviceIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = superviseAdapter(ctx, id, opts, states)
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
 		return nil
-	case errors.Is(err, bluetooth.ErrInit):
-		return fmt.Errorf("%v\nDid you set the capability?\n$ sudo setcap cap_net_raw,cap_net_admin=ep /path/to/reader", err)
-	default:
-		return err
 	}
+	return fmt.Errorf("%w: %v", ErrAdaptersFailed, errors.Join(errs...))
+}
+
+func superviseAdapter(ctx context.Context, id int, opts *RunBluetoothOpts, states *AdapterStates) error {
+	adapter := bluetooth.AdapterName(id)
+	backoff := opts.RestartBackoffMin
+
+	for {
+		var received atomic.Bool
+		states.update(adapter, func(s *AdapterState) { s.Running = true })
+
+		err := bluetoothRun(ctx, func(a *bluetooth.Advertisement) {
+			received.Store(true)
+			handleAdvertisement(a, opts.Format8Keys, opts.TagTracker, opts.CachePointF)
+		}, &bluetooth.Config{
+			DeviceID:        id,
+			WatchdogTimeout: opts.WatchdogTimeout,
+		})
+
+		if ctx.Err() != nil {
+			states.update(adapter, func(s *AdapterState) { s.Running = false })
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("%w: %s: scan stopped", bluetooth.ErrScan, adapter)
+		}
+		if errors.Is(err, bluetooth.ErrInit) {
+			err = fmt.Errorf("%w\nDid you set the capability?\n$ sudo setcap cap_net_raw,cap_net_admin=ep /path/to/reader", err)
+		}
+		log.Print(err)
+
+		// A scan that received data was healthy for a while, so the failure streak starts over.
+		if received.Load() {
+			backoff = opts.RestartBackoffMin
+		}
+
+		var failures int
+		states.update(adapter, func(s *AdapterState) {
+			if received.Load() {
+				s.ConsecutiveFailures = 0
+			}
+			s.Running = false
+			s.ConsecutiveFailures++
+			s.LastError = err.Error()
+			s.LastErrorTime = time.Now()
+			failures = s.ConsecutiveFailures
+		})
+
+		if opts.MaxFailures > 0 && failures >= opts.MaxFailures {
+			states.update(adapter, func(s *AdapterState) { s.GaveUp = true })
+			return fmt.Errorf("%s: giving up after %d consecutive failures: %w", adapter, failures, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, opts.RestartBackoffMax)
+
+		if err := bluetoothReset(id); err != nil {
+			log.Print(err)
+		}
+		states.update(adapter, func(s *AdapterState) { s.Restarts++ })
+	}
+}
+
+// AdapterState describes the health of an adapter's scan loop.
+type AdapterState struct {
+	Adapter string
+	Running bool
+
+	Restarts            uint64
+	ConsecutiveFailures int
+	LastError           string    `json:",omitempty"`
+	LastErrorTime       time.Time `json:",omitempty"`
+
+	// GaveUp is set once the adapter exceeded the allowed number of consecutive failures.
+	GaveUp bool
+}
+
+// AdapterStates tracks the scan loops of all adapters.
+type AdapterStates struct {
+	mu     sync.Mutex
+	states map[string]*AdapterState
+}
+
+// Read returns a snapshot of the states, sorted by adapter.
+func (a *AdapterStates) Read() []*AdapterState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var ret []*AdapterState
+	for _, s := range a.states {
+		s := *s
+		ret = append(ret, &s)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Adapter < ret[j].Adapter })
+	return ret
+}
+
+func (a *AdapterStates) update(adapter string, f func(*AdapterState)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.states == nil {
+		a.states = map[string]*AdapterState{}
+	}
+	s, ok := a.states[adapter]
+	if !ok {
+		s = &AdapterState{Adapter: adapter}
+		a.states[adapter] = s
+	}
+	f(s)
+}
+
+// handleAdvertisement parses an advertisement from any source, records it with tags (if set) and caches the result.
+func handleAdvertisement(a *bluetooth.Advertisement, format8Keys map[string][]byte, tags *TagTracker, cachePointF func(*data.Point)) {
+	p, err := parseAdvertisement(a, &protocol.ParseOpts{
+		Format8Keys: format8Keys,
+	})
+	if tags != nil {
+		tags.Observe(a, p, err)
+	}
+	if err != nil {
+		return
+	}
+	cachePointF(p)
+}
+
+// advertisedPoint fills in the parts of p that come from the advertisement rather than its payload.
+func advertisedPoint(a *bluetooth.Advertisement, p *data.Point) *data.Point {
+	p.RSSI = data.Ptr(a.RSSI)
+	p.Adapter = a.Adapter
+	if !a.Timestamp.IsZero() {
+		p.Timestamp = a.Timestamp
+	}
+	return p
+}
+
+// parseAdvertisement tries manufacturer data first, then each of the service data entries.
+func parseAdvertisement(a *bluetooth.Advertisement, opts *protocol.ParseOpts) (*data.Point, error) {
+	var errs []error
+
+	if a.ManufacturerData != nil {
+		p, err := protocol.ParseDatagram(a.ManufacturerID, a.ManufacturerData, a.Addr, opts)
+		if err == nil {
+			return advertisedPoint(a, p), nil
+		}
+		errs = append(errs, err)
+	}
+
+	for uuid, sd := range a.ServiceData {
+		p, err := protocol.ParseServiceData(uuid, sd, a.Addr, opts)
+		if err == nil {
+			return advertisedPoint(a, p), nil
+		}
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no data in advertisement from %s", a.Addr)
+	}
+	return nil, errors.Join(errs...)
 }
@@ -0,0 +1,46 @@
+package bluetooth
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	adTypeServiceData16    = 0x16
+	adTypeManufacturerData = 0xFF
+)
+
+// ParseAdvertisingData extracts manufacturer and service data from raw advertising data structures,
+// as found in HCI advertising reports or relayed by gateways. Addr and RSSI are left for the caller to fill in.
+// It returns nil if neither manufacturer nor service data is present.
+func ParseAdvertisingData(b []byte) (*Advertisement, error) {
+	adv := &Advertisement{}
+
+	for len(b) > 0 {
+		l := int(b[0])
+		if l == 0 {
+			break
+		}
+		if gotLen, wantLen := len(b)-1, l; gotLen < wantLen {
+			return nil, fmt.Errorf("AD structure too short (got %d, want %d)", gotLen, wantLen)
+		}
+		typ, val := b[1], b[2:1+l]
+		b = b[1+l:]
+
+		switch {
+		case typ == adTypeManufacturerData && len(val) >= 2:
+			adv.ManufacturerID = binary.LittleEndian.Uint16(val[0:2])
+			adv.ManufacturerData = val[2:]
+		case typ == adTypeServiceData16 && len(val) >= 2:
+			if adv.ServiceData == nil {
+				adv.ServiceData = map[uint16][]byte{}
+			}
+			adv.ServiceData[binary.LittleEndian.Uint16(val[0:2])] = val[2:]
+		}
+	}
+
+	if adv.ManufacturerData == nil && adv.ServiceData == nil {
+		return nil, nil
+	}
+	return adv, nil
+}
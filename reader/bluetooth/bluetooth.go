@@ -17,11 +17,46 @@ var (
 	ErrWatchdog = fmt.Errorf("bluetooth watchdog error")
 )
 
-func Run(ctx context.Context, callback func(addr string, mfID uint16, data []byte), watchdogTimeout time.Duration) error {
-	d, err := linux.NewDevice()
+// Advertisement contains the parts of a BLE advertisement that carry sensor data.
+type Advertisement struct {
+	Addr string
+	RSSI int
+	// Adapter is the HCI device that received the advertisement, e.g. hci0; empty for other sources.
+	Adapter string
+	// Timestamp is when the advertisement was received, if known from the source (e.g. a gateway);
+	// zero means now.
+	Timestamp time.Time
+
+	// ManufacturerID and ManufacturerData are set if the advertisement carries manufacturer specific data.
+	ManufacturerID   uint16
+	ManufacturerData []byte
+
+	// ServiceData maps 16-bit service UUIDs to their service data.
+	ServiceData map[uint16][]byte
+}
+
+// Config contains options for scanning with a single adapter.
+type Config struct {
+	// DeviceID selects the HCI device, e.g. 1 for hci1.
+	DeviceID int
+	// WatchdogTimeout stops the scan if nothing is received for that long; 0 disables the watchdog.
+	WatchdogTimeout time.Duration
+}
+
+// AdapterName returns the name of the HCI device with the given ID.
+func AdapterName(id int) string {
+	return fmt.Sprintf("hci%d", id)
+}
+
+func Run(ctx context.Context, callback func(*Advertisement), cfg *Config) error {
+	watchdogTimeout := cfg.WatchdogTimeout
+	adapter := AdapterName(cfg.DeviceID)
+
+	d, err := linux.NewDevice(ble.OptDeviceID(cfg.DeviceID))
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInit, err)
+		return fmt.Errorf("%w: %s: %v", ErrInit, adapter, err)
 	}
+	defer d.Stop()
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -29,7 +64,8 @@ func Run(ctx context.Context, callback func(addr string, mfID uint16, data []byt
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
-	watchdogCh := make(chan bool)
+	// Kicks never block, so that the scan can be stopped after the watchdog has fired.
+	watchdogCh := make(chan bool, 1)
 	watchdogErr := false
 	if watchdogTimeout != 0 {
 		wg.Add(1)
@@ -57,19 +93,43 @@ func Run(ctx context.Context, callback func(addr string, mfID uint16, data []byt
 
 	if err := d.Scan(ctx, false, func(a ble.Advertisement) {
 		if watchdogTimeout != 0 {
-			watchdogCh <- true
+			select {
+			case watchdogCh <- true:
+			default:
+			}
+		}
+
+		adv := &Advertisement{
+			Addr:    a.Addr().String(),
+			RSSI:    a.RSSI(),
+			Adapter: adapter,
+		}
+
+		if md := a.ManufacturerData(); len(md) >= 2 {
+			adv.ManufacturerID = binary.LittleEndian.Uint16(md[0:2])
+			adv.ManufacturerData = md[2:]
+		}
+
+		for _, sd := range a.ServiceData() {
+			if sd.UUID.Len() != 2 {
+				continue
+			}
+			if adv.ServiceData == nil {
+				adv.ServiceData = map[uint16][]byte{}
+			}
+			adv.ServiceData[binary.LittleEndian.Uint16(sd.UUID)] = sd.Data
 		}
 
-		if len(a.ManufacturerData()) < 2 {
+		if adv.ManufacturerData == nil && adv.ServiceData == nil {
 			return
 		}
-		callback(a.Addr().String(), binary.LittleEndian.Uint16(a.ManufacturerData()[0:2]), a.ManufacturerData()[2:])
+		callback(adv)
 	}); err != nil && err != context.Canceled {
-		return fmt.Errorf("%w: %v", ErrScan, err)
+		return fmt.Errorf("%w: %s: %v", ErrScan, adapter, err)
 	}
 
 	if watchdogErr {
-		return fmt.Errorf("%w: timed out (%v)", ErrWatchdog, watchdogTimeout)
+		return fmt.Errorf("%w: %s: timed out (%v)", ErrWatchdog, adapter, watchdogTimeout)
 	}
 	return nil
 }
@@ -0,0 +1,29 @@
+package bluetooth
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	ErrReset = fmt.Errorf("bluetooth reset error")
+)
+
+// HCIDEVRESET, _IOW('H', 203, int).
+const ioctlHCIDevReset = 0x400448CB
+
+// Reset asks the kernel to reset the HCI device with the given ID, like `hciconfig hciX reset`.
+// It's meant for recovering an adapter that stopped responding; the device must not be in use.
+func Reset(id int) error {
+	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.BTPROTO_HCI)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrReset, AdapterName(id), err)
+	}
+	defer unix.Close(fd)
+
+	if err := unix.IoctlSetInt(fd, ioctlHCIDevReset, id); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrReset, AdapterName(id), err)
+	}
+	return nil
+}
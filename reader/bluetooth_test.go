@@ -0,0 +1,153 @@
+package reader
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+)
+
+func TestRunBluetoothSupervision(t *testing.T) {
+	defer func(run func(context.Context, func(*bluetooth.Advertisement), *bluetooth.Config) error, reset func(int) error) {
+		bluetoothRun, bluetoothReset = run, reset
+	}(bluetoothRun, bluetoothReset)
+
+	df5, _ := hex.DecodeString("0512FC5394C37C0004FFFC040CAC364200CDCBB8334C884F")
+	watchdogErr := fmt.Errorf("%w: timed out", bluetooth.ErrWatchdog)
+
+	for _, tc := range []struct {
+		name string
+		// scan is called with the 1-based attempt number.
+		scan        func(ctx context.Context, cancel func(), attempt int, callback func(*bluetooth.Advertisement)) error
+		maxFailures int
+		wantErr     error
+		wantState   *AdapterState
+		wantPoints  int
+	}{
+		{
+			name: "gives up",
+			scan: func(context.Context, func(), int, func(*bluetooth.Advertisement)) error {
+				return watchdogErr
+			},
+			maxFailures: 3,
+			wantErr:     ErrAdaptersFailed,
+			wantState: &AdapterState{
+				Adapter:             "hci0",
+				Restarts:            2,
+				ConsecutiveFailures: 3,
+				LastError:           watchdogErr.Error(),
+				GaveUp:              true,
+			},
+		},
+		{
+			name: "receiving data resets failure streak",
+			scan: func(ctx context.Context, cancel func(), attempt int, callback func(*bluetooth.Advertisement)) error {
+				switch attempt {
+				case 1:
+					return watchdogErr
+				case 2:
+					callback(&bluetooth.Advertisement{Addr: "cb:b8:33:4c:88:4f", Adapter: "hci0", ManufacturerID: 0x0499, ManufacturerData: df5})
+					return watchdogErr
+				default:
+					cancel()
+					<-ctx.Done()
+					return nil
+				}
+			},
+			maxFailures: 2,
+			wantState: &AdapterState{
+				Adapter:             "hci0",
+				Restarts:            2,
+				ConsecutiveFailures: 1,
+				LastError:           watchdogErr.Error(),
+			},
+			wantPoints: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var attempts, resets int
+			bluetoothRun = func(ctx context.Context, callback func(*bluetooth.Advertisement), cfg *bluetooth.Config) error {
+				attempts++
+				return tc.scan(ctx, cancel, attempts, callback)
+			}
+			bluetoothReset = func(int) error {
+				resets++
+				return nil
+			}
+
+			var points []*data.Point
+			states := &AdapterStates{}
+			err := RunBluetooth(ctx, &RunBluetoothOpts{
+				DeviceIDs:         []int{0},
+				RestartBackoffMin: time.Millisecond,
+				RestartBackoffMax: 4 * time.Millisecond,
+				MaxFailures:       tc.maxFailures,
+				CachePointF:       func(p *data.Point) { points = append(points, p) },
+				States:            states,
+			})
+			if !errors.Is(err, tc.wantErr) || (err == nil) != (tc.wantErr == nil) {
+				t.Errorf("RunBluetooth = %v, want %v", err, tc.wantErr)
+			}
+
+			if diff := cmp.Diff([]*AdapterState{tc.wantState}, states.Read(), cmpopts.IgnoreFields(AdapterState{}, "LastErrorTime")); diff != "" {
+				t.Errorf("AdapterStates diff -want +got\n%v", diff)
+			}
+			if got, want := resets, int(tc.wantState.Restarts); got != want {
+				t.Errorf("resets = %d, want %d", got, want)
+			}
+			if got, want := len(points), tc.wantPoints; got != want {
+				t.Fatalf("len(points) = %d, want %d", got, want)
+			}
+			for _, p := range points {
+				if got, want := p.Adapter, "hci0"; got != want {
+					t.Errorf("Adapter = %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseAdvertisementTimestamp(t *testing.T) {
+	df5, _ := hex.DecodeString("0512FC5394C37C0004FFFC040CAC364200CDCBB8334C884F")
+
+	for _, tc := range []struct {
+		name string
+		ts   time.Time
+	}{
+		{name: "received now"},
+		{name: "received earlier", ts: time.Unix(1700000000, 0)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			before := time.Now()
+			p, err := parseAdvertisement(&bluetooth.Advertisement{
+				Addr:             "cb:b8:33:4c:88:4f",
+				Timestamp:        tc.ts,
+				ManufacturerID:   0x0499,
+				ManufacturerData: df5,
+			}, nil)
+			if err != nil {
+				t.Fatalf("parseAdvertisement failed: %v", err)
+			}
+
+			if tc.ts.IsZero() {
+				if p.Timestamp.Before(before) {
+					t.Errorf("p.Timestamp = %v, want at least %v", p.Timestamp, before)
+				}
+				return
+			}
+			if !p.Timestamp.Equal(tc.ts) {
+				t.Errorf("p.Timestamp = %v, want %v", p.Timestamp, tc.ts)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package reader
 
 import (
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -11,9 +12,33 @@ import (
 type MakeCacheOpts struct {
 	MaxStaleness time.Duration
 	MACFilter    []string
+
+	// StuckTimeout marks a tag as stuck once it repeats a single sequence number for longer than that.
+	// Zero disables the check.
+	StuckTimeout time.Duration
+
+	// NewPointF, if set, is called with every new measurement that passes the filter; re-broadcasts are skipped.
+	// It's called with the cache locked, so it must not block.
+	NewPointF func(*data.Point)
+}
+
+// SequenceState describes the measurement sequence of a tag.
+type SequenceState struct {
+	Address  string
+	Sequence uint32
+
+	// FirstSeen is when Sequence was first received; LastSeen is when it was most recently re-broadcast.
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Repeats   uint64
+
+	Stuck bool
 }
 
-func MakeCache(opts *MakeCacheOpts) (get func() []*data.Point, put func(*data.Point)) {
+// MakeCache returns functions operating on a cache of most recent points.
+// Re-broadcasts of a measurement (same MAC and sequence number) keep the timestamp of the first broadcast.
+// Points without a sequence number (e.g. formats that don't carry one) are not deduplicated.
+func MakeCache(opts *MakeCacheOpts) (get func() []*data.Point, put func(*data.Point), sequences func() []*SequenceState) {
 	filter := map[string]bool{}
 	for _, m := range opts.MACFilter {
 		filter[strings.ToUpper(m)] = true
@@ -21,6 +46,7 @@ func MakeCache(opts *MakeCacheOpts) (get func() []*data.Point, put func(*data.Po
 
 	var mu sync.Mutex
 	points := map[string]*data.Point{}
+	seqs := map[string]*SequenceState{}
 
 	getF := func() []*data.Point {
 		mu.Lock()
@@ -41,9 +67,56 @@ func MakeCache(opts *MakeCacheOpts) (get func() []*data.Point, put func(*data.Po
 		mu.Lock()
 		defer mu.Unlock()
 
-		if len(filter) == 0 || filter[p.Address] {
-			points[p.Address] = p
+		if len(filter) != 0 && !filter[p.Address] {
+			return
+		}
+
+		// Re-broadcasts get their timestamp adjusted; keep that from the caller.
+		cp := *p
+		p = &cp
+
+		if p.MeasurementSequence != nil {
+			switch s := seqs[p.Address]; {
+			case s == nil || s.Sequence != *p.MeasurementSequence:
+				seqs[p.Address] = &SequenceState{
+					Address:   p.Address,
+					Sequence:  *p.MeasurementSequence,
+					FirstSeen: p.Timestamp,
+					LastSeen:  p.Timestamp,
+				}
+			default:
+				s.LastSeen = p.Timestamp
+				s.Repeats++
+				p.Timestamp = s.FirstSeen
+				points[p.Address] = p
+				return
+			}
+		}
+
+		points[p.Address] = p
+		if opts.NewPointF != nil {
+			opts.NewPointF(p)
 		}
 	}
-	return getF, putF
+
+	sequencesF := func() []*SequenceState {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var ret []*SequenceState
+		for k, v := range seqs {
+			if v.LastSeen.Add(opts.MaxStaleness).Before(time.Now()) {
+				delete(seqs, k)
+				continue
+			}
+
+			s := *v
+			s.Stuck = opts.StuckTimeout > 0 && s.LastSeen.Sub(s.FirstSeen) > opts.StuckTimeout
+			ret = append(ret, &s)
+		}
+		sort.Slice(ret, func(i, j int) bool { return ret[i].Address < ret[j].Address })
+		return ret
+	}
+
+	return getF, putF, sequencesF
 }
@@ -0,0 +1,70 @@
+package reader
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func TestCacheSequences(t *testing.T) {
+	t0 := time.Now().Add(-10 * time.Minute)
+
+	var newPoints []string
+	get, put, sequences := MakeCache(&MakeCacheOpts{
+		MaxStaleness: time.Hour,
+		StuckTimeout: time.Minute,
+		NewPointF: func(p *data.Point) {
+			newPoints = append(newPoints, fmt.Sprintf("%s@%v", p.Address, p.Timestamp.Sub(t0)))
+		},
+	})
+
+	p := func(addr string, seq uint32, ts time.Time) *data.Point {
+		ret := &data.Point{Address: addr, Timestamp: ts}
+		if seq != 0 {
+			ret.MeasurementSequence = &seq
+		}
+		return ret
+	}
+
+	// AA advances normally, BB repeats a single sequence number, CC doesn't carry one.
+	put(p("AA", 1, t0))
+	put(p("AA", 1, t0.Add(time.Second)))
+	put(p("AA", 2, t0.Add(2*time.Second)))
+	put(p("BB", 7, t0))
+	rebroadcast := p("BB", 7, t0.Add(5*time.Minute))
+	put(rebroadcast)
+	if got, want := rebroadcast.Timestamp, t0.Add(5*time.Minute); !got.Equal(want) {
+		t.Errorf("put changed the timestamp of its argument to %v, want %v", got, want)
+	}
+	put(p("CC", 0, t0))
+	put(p("CC", 0, t0.Add(time.Second)))
+
+	gotTS := map[string]time.Time{}
+	for _, p := range get() {
+		gotTS[p.Address] = p.Timestamp
+	}
+	wantTS := map[string]time.Time{
+		"AA": t0.Add(2 * time.Second),
+		"BB": t0,
+		"CC": t0.Add(time.Second),
+	}
+	if diff := cmp.Diff(wantTS, gotTS); diff != "" {
+		t.Errorf("get timestamps diff -want +got\n%v", diff)
+	}
+
+	want := []*SequenceState{
+		{Address: "AA", Sequence: 2, FirstSeen: t0.Add(2 * time.Second), LastSeen: t0.Add(2 * time.Second)},
+		{Address: "BB", Sequence: 7, FirstSeen: t0, LastSeen: t0.Add(5 * time.Minute), Repeats: 1, Stuck: true},
+	}
+	if diff := cmp.Diff(want, sequences()); diff != "" {
+		t.Errorf("sequences diff -want +got\n%v", diff)
+	}
+
+	wantNew := []string{"AA@0s", "AA@2s", "BB@0s", "CC@0s", "CC@1s"}
+	if diff := cmp.Diff(wantNew, newPoints); diff != "" {
+		t.Errorf("new points diff -want +got\n%v", diff)
+	}
+}
@@ -1,21 +1,106 @@
 package reader
 
 import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 )
 
 type Config struct {
 	ProvidedEndpoints struct {
-		Data string `yaml:"data"`
+		Data    string `yaml:"data"`
+		Gateway string `yaml:"gateway"`
 	} `yaml:"provided_endpoints"`
 
+	ConsumedEndpoints struct {
+		Storage string `yaml:"storage"`
+	} `yaml:"consumed_endpoints"`
+
+	Push struct {
+		BatchSize        int           `yaml:"batch_size"`
+		FlushPeriod      time.Duration `yaml:"flush_period"`
+		RetryBackoffMax  time.Duration `yaml:"retry_backoff_max"`
+		OutboxPath       string        `yaml:"outbox_path"`
+		OutboxMaxBatches int           `yaml:"outbox_max_batches"`
+	} `yaml:"push"`
+
 	Bluetooth struct {
+		Disabled        bool          `yaml:"disabled"`
+		Devices         []int         `yaml:"devices"`
 		WatchdogTimeout time.Duration `yaml:"watchdog_timeout"`
+
+		RestartBackoffMin time.Duration `yaml:"restart_backoff_min"`
+		RestartBackoffMax time.Duration `yaml:"restart_backoff_max"`
+		MaxFailures       int           `yaml:"max_failures"`
 	} `yaml:"bluetooth"`
 
+	Replay struct {
+		Path     string `yaml:"path"`
+		Realtime bool   `yaml:"realtime"`
+		Loop     bool   `yaml:"loop"`
+	} `yaml:"replay"`
+
+	MQTT struct {
+		Broker   string   `yaml:"broker"`
+		ClientID string   `yaml:"client_id"`
+		Username string   `yaml:"username"`
+		Password string   `yaml:"password"`
+		Topics   []string `yaml:"topics"`
+
+		TLS struct {
+			Enabled            bool   `yaml:"enabled"`
+			CAFile             string `yaml:"ca_file"`
+			CertFile           string `yaml:"cert_file"`
+			KeyFile            string `yaml:"key_file"`
+			InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+		} `yaml:"tls"`
+	} `yaml:"mqtt"`
+
+	Simulator struct {
+		Interval      time.Duration `yaml:"interval"`
+		Noise         float64       `yaml:"noise"`
+		DropoutRate   float64       `yaml:"dropout_rate"`
+		MalformedRate float64       `yaml:"malformed_rate"`
+		BatteryDrain  float64       `yaml:"battery_drain"`
+		Seed          int64         `yaml:"seed"`
+
+		Tags []struct {
+			Address              string  `yaml:"address"`
+			Format               int     `yaml:"format"`
+			Temperature          float64 `yaml:"temperature"`
+			TemperatureAmplitude float64 `yaml:"temperature_amplitude"`
+			Humidity             float64 `yaml:"humidity"`
+			Pressure             float64 `yaml:"pressure"`
+			Battery              float64 `yaml:"battery"`
+		} `yaml:"tags"`
+	} `yaml:"simulator"`
+
+	Protocol struct {
+		Format8Keys map[string]string `yaml:"format8_keys"`
+
+		format8Keys map[string][]byte
+	} `yaml:"protocol"`
+
+	History struct {
+		MaxAge     time.Duration `yaml:"max_age"`
+		MaxPoints  int           `yaml:"max_points"`
+		Path       string        `yaml:"path"`
+		SavePeriod time.Duration `yaml:"save_period"`
+	} `yaml:"history"`
+
+	Events struct {
+		HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+	} `yaml:"events"`
+
 	Data struct {
 		MaxStaleness time.Duration `yaml:"max_staleness"`
 		MACFilter    []string      `yaml:"mac_filter"`
+		StuckTimeout time.Duration `yaml:"stuck_timeout"`
+		TagsMaxAge   time.Duration `yaml:"tags_max_age"`
 	} `yaml:"data"`
 }
 
@@ -23,5 +108,80 @@ func (cfg *Config) Sanitize() error {
 	if cfg == nil {
 		return nil
 	}
+
+	if len(cfg.Bluetooth.Devices) == 0 {
+		cfg.Bluetooth.Devices = []int{0}
+	}
+	slices.Sort(cfg.Bluetooth.Devices)
+	cfg.Bluetooth.Devices = slices.Compact(cfg.Bluetooth.Devices)
+	if cfg.Bluetooth.RestartBackoffMin <= 0 {
+		cfg.Bluetooth.RestartBackoffMin = time.Second
+	}
+	if cfg.Bluetooth.RestartBackoffMax < cfg.Bluetooth.RestartBackoffMin {
+		cfg.Bluetooth.RestartBackoffMax = max(5*time.Minute, cfg.Bluetooth.RestartBackoffMin)
+	}
+
+	cfg.Replay.Path = sanitizePath(cfg.Replay.Path)
+
+	if cfg.History.MaxAge <= 0 && cfg.History.MaxPoints <= 0 {
+		cfg.History.MaxAge = time.Hour
+	}
+	if cfg.History.SavePeriod <= 0 {
+		cfg.History.SavePeriod = time.Minute
+	}
+	cfg.History.Path = sanitizePath(cfg.History.Path)
+
+	if cfg.Data.TagsMaxAge <= 0 {
+		cfg.Data.TagsMaxAge = time.Hour
+	}
+
+	if cfg.Events.HeartbeatInterval <= 0 {
+		cfg.Events.HeartbeatInterval = 15 * time.Second
+	}
+
+	if cfg.Push.BatchSize <= 0 {
+		cfg.Push.BatchSize = 500
+	}
+	if cfg.Push.FlushPeriod <= 0 {
+		cfg.Push.FlushPeriod = 10 * time.Second
+	}
+	if cfg.Push.RetryBackoffMax < cfg.Push.FlushPeriod {
+		cfg.Push.RetryBackoffMax = max(5*time.Minute, cfg.Push.FlushPeriod)
+	}
+	if cfg.Push.OutboxMaxBatches <= 0 {
+		cfg.Push.OutboxMaxBatches = 10000
+	}
+	cfg.Push.OutboxPath = sanitizePath(cfg.Push.OutboxPath)
+
+	if cfg.MQTT.ClientID == "" {
+		cfg.MQTT.ClientID = "ruuvi2db"
+	}
+	if len(cfg.MQTT.Topics) == 0 {
+		cfg.MQTT.Topics = []string{"ruuvi/#"}
+	}
+	cfg.MQTT.TLS.CAFile = sanitizePath(cfg.MQTT.TLS.CAFile)
+	cfg.MQTT.TLS.CertFile = sanitizePath(cfg.MQTT.TLS.CertFile)
+	cfg.MQTT.TLS.KeyFile = sanitizePath(cfg.MQTT.TLS.KeyFile)
+
+	cfg.Protocol.format8Keys = map[string][]byte{}
+	for mac, key := range cfg.Protocol.Format8Keys {
+		b, err := hex.DecodeString(key)
+		if err != nil {
+			return fmt.Errorf("malformed format8 key for %q: %v", mac, err)
+		}
+		if got, want := len(b), 16; got != want {
+			return fmt.Errorf("malformed format8 key for %q: got %d bytes, want %d", mac, got, want)
+		}
+		cfg.Protocol.format8Keys[strings.ToUpper(mac)] = b
+	}
+
 	return nil
 }
+
+func sanitizePath(path string) string {
+	if x, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(os.Getenv("HOME"), x)
+	}
+
+	return path
+}
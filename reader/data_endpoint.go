@@ -4,15 +4,27 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/reader/protocol"
 )
 
 type RunDataEndpointOpts struct {
-	Listen  string
-	PointsF func() []*data.Point
+	Listen       string
+	PointsF      func() []*data.Point
+	HistoryF     func(since time.Time) []*data.Point
+	ParserStatsF func() *protocol.Stats
+	SequencesF   func() []*SequenceState
+	AdaptersF    func() []*AdapterState
+	TagsF        func() []*TagInfo
+	Events       *EventHub
+	// HeartbeatInterval is the period of keep-alive comments on /events.
+	HeartbeatInterval time.Duration
 }
 
 func RunDataEndpoint(ctx context.Context, opts *RunDataEndpointOpts) error {
@@ -22,16 +34,30 @@ func RunDataEndpoint(ctx context.Context, opts *RunDataEndpointOpts) error {
 	srv.ReadTimeout = time.Minute
 	srv.WriteTimeout = time.Minute
 	srv.SetKeepAlivesEnabled(false)
+	// Lets long-lived /events streams end on shutdown.
+	srv.BaseContext = func(net.Listener) context.Context { return ctx }
 
 	mux := http.NewServeMux()
 
 	mux.Handle("/data.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since, ok, err := dataSince(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 
 		e := json.NewEncoder(w)
 		e.SetIndent("", "  ")
 
-		d := opts.PointsF()
+		// Without "since", only the latest point of each tag is returned.
+		var d []*data.Point
+		if ok {
+			d = opts.HistoryF(since)
+		} else {
+			d = opts.PointsF()
+		}
 		if len(d) == 0 {
 			d = []*data.Point{}
 		}
@@ -42,6 +68,79 @@ func RunDataEndpoint(ctx context.Context, opts *RunDataEndpointOpts) error {
 		}
 	}))
 
+	mux.Handle("/parsers.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+
+		if err := e.Encode(opts.ParserStatsF()); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}))
+
+	mux.Handle("/sequences.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+
+		d := opts.SequencesF()
+		if len(d) == 0 {
+			d = []*SequenceState{}
+		}
+
+		if err := e.Encode(d); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}))
+
+	mux.Handle("/adapters.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+
+		d := opts.AdaptersF()
+		if len(d) == 0 {
+			d = []*AdapterState{}
+		}
+
+		if err := e.Encode(d); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}))
+
+	mux.Handle("/tags.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if opts.TagsF == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+
+		d := opts.TagsF()
+		if len(d) == 0 {
+			d = []*TagInfo{}
+		}
+
+		if err := e.Encode(d); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}))
+
+	mux.Handle("/events", EventsHandler(&EventsHandlerOpts{
+		Hub:               opts.Events,
+		HeartbeatInterval: opts.HeartbeatInterval,
+	}))
+
 	srv.Handler = mux
 
 	go func() {
@@ -56,3 +155,20 @@ func RunDataEndpoint(ctx context.Context, opts *RunDataEndpointOpts) error {
 		return err
 	}
 }
+
+// dataSince parses the "since" parameter, given as Unix seconds or RFC 3339.
+func dataSince(r *http.Request) (time.Time, bool, error) {
+	x := r.URL.Query().Get("since")
+	if x == "" {
+		return time.Time{}, false, nil
+	}
+
+	if sec, err := strconv.ParseInt(x, 10, 64); err == nil {
+		return time.Unix(sec, 0), true, nil
+	}
+	ts, err := time.Parse(time.RFC3339Nano, x)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("malformed since %q", x)
+	}
+	return ts, true, nil
+}
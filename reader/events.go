@@ -0,0 +1,225 @@
+package reader
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s5i/ruuvi2db/data"
+)
+
+const (
+	// eventsBufferLen is the number of points buffered per client; once full, points are dropped for that client.
+	eventsBufferLen = 256
+	// eventsWriteTimeout disconnects clients that stop reading.
+	eventsWriteTimeout = 30 * time.Second
+)
+
+// EventHub fans out new points to /events clients.
+// Publishing never blocks: a client that falls behind loses points instead of stalling the sources.
+type EventHub struct {
+	mu   sync.Mutex
+	subs map[*eventSub]bool
+}
+
+type eventSub struct {
+	ch      chan *data.Point
+	dropped uint64
+}
+
+// Publish sends p to all clients.
+func (h *EventHub) Publish(p *data.Point) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subs {
+		select {
+		case s.ch <- p:
+		default:
+			s.dropped++
+		}
+	}
+}
+
+func (h *EventHub) subscribe() *eventSub {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subs == nil {
+		h.subs = map[*eventSub]bool{}
+	}
+	s := &eventSub{ch: make(chan *data.Point, eventsBufferLen)}
+	h.subs[s] = true
+	return s
+}
+
+func (h *EventHub) unsubscribe(s *eventSub) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.subs, s)
+}
+
+// takeDropped returns the number of points dropped since the last call.
+func (h *EventHub) takeDropped(s *eventSub) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ret := s.dropped
+	s.dropped = 0
+	return ret
+}
+
+type EventsHandlerOpts struct {
+	Hub               *EventHub
+	HeartbeatInterval time.Duration
+}
+
+// EventsHandler streams new points as Server-Sent Events.
+// Optional "mac" and "kind" parameters (repeated or comma-separated) limit the tags and fields sent.
+// Points are sent as "point" events; a "dropped" event reports points lost because the client was too slow.
+func EventsHandler(opts *EventsHandlerOpts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		macs := map[string]bool{}
+		for _, m := range listParam(r, "mac") {
+			macs[strings.ToUpper(m)] = true
+		}
+
+		kinds := listParam(r, "kind")
+		for _, k := range kinds {
+			if !slices.Contains(eventKinds, k) {
+				http.Error(w, fmt.Sprintf("unrecognized kind %q; valid: %q", k, eventKinds), http.StatusBadRequest)
+				return
+			}
+		}
+
+		rc := http.NewResponseController(w)
+		write := func(format string, args ...any) error {
+			rc.SetWriteDeadline(time.Now().Add(eventsWriteTimeout))
+			if _, err := fmt.Fprintf(w, format, args...); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		s := opts.Hub.subscribe()
+		defer opts.Hub.unsubscribe(s)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		if err := write(": connected\n\n"); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(opts.HeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+
+			case <-heartbeat.C:
+				if err := write(": heartbeat\n\n"); err != nil {
+					return
+				}
+
+			case p := <-s.ch:
+				if n := opts.Hub.takeDropped(s); n > 0 {
+					if err := write("event: dropped\ndata: %d\n\n", n); err != nil {
+						return
+					}
+				}
+
+				if len(macs) > 0 && !macs[strings.ToUpper(p.Address)] {
+					continue
+				}
+				if len(kinds) > 0 {
+					if p = selectKinds(p, kinds); p == nil {
+						continue
+					}
+				}
+
+				b, err := json.Marshal(p)
+				if err != nil {
+					continue
+				}
+				if err := write("event: point\ndata: %s\n\n", b); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+var eventKinds = []string{"temperature", "humidity", "pressure", "battery", "acceleration_x", "acceleration_y", "acceleration_z", "movement_counter", "measurement_sequence", "rssi", "tx_power"}
+
+// selectKinds returns a copy of p with only the given kinds set, or nil if none of them are present.
+func selectKinds(p *data.Point, kinds []string) *data.Point {
+	ret := &data.Point{
+		Address:   p.Address,
+		Timestamp: p.Timestamp,
+		Adapter:   p.Adapter,
+	}
+
+	found := false
+	for _, k := range kinds {
+		switch k {
+		case "temperature":
+			ret.Temperature = p.Temperature
+			found = found || p.Temperature != nil
+		case "humidity":
+			ret.Humidity = p.Humidity
+			found = found || p.Humidity != nil
+		case "pressure":
+			ret.Pressure = p.Pressure
+			found = found || p.Pressure != nil
+		case "battery":
+			ret.Battery = p.Battery
+			found = found || p.Battery != nil
+		case "acceleration_x":
+			ret.AccelerationX = p.AccelerationX
+			found = found || p.AccelerationX != nil
+		case "acceleration_y":
+			ret.AccelerationY = p.AccelerationY
+			found = found || p.AccelerationY != nil
+		case "acceleration_z":
+			ret.AccelerationZ = p.AccelerationZ
+			found = found || p.AccelerationZ != nil
+		case "movement_counter":
+			ret.MovementCounter = p.MovementCounter
+			found = found || p.MovementCounter != nil
+		case "measurement_sequence":
+			ret.MeasurementSequence = p.MeasurementSequence
+			found = found || p.MeasurementSequence != nil
+		case "rssi":
+			ret.RSSI = p.RSSI
+			found = found || p.RSSI != nil
+		case "tx_power":
+			ret.TxPower = p.TxPower
+			found = found || p.TxPower != nil
+		}
+	}
+
+	if !found {
+		return nil
+	}
+	return ret
+}
+
+// listParam returns all values of a repeated or comma-separated query parameter.
+func listParam(r *http.Request, name string) []string {
+	var ret []string
+	for _, v := range r.URL.Query()[name] {
+		for _, x := range strings.Split(v, ",") {
+			if x = strings.TrimSpace(x); x != "" {
+				ret = append(ret, x)
+			}
+		}
+	}
+	return ret
+}
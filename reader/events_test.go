@@ -0,0 +1,84 @@
+package reader
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func TestEventsHandler(t *testing.T) {
+	hub := &EventHub{}
+	srv := httptest.NewServer(EventsHandler(&EventsHandlerOpts{
+		Hub:               hub,
+		HeartbeatInterval: time.Hour,
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?mac=aa:aa:aa:aa:aa:aa&kind=temperature,humidity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	lines := bufio.NewScanner(resp.Body)
+	next := func() string {
+		if !lines.Scan() {
+			t.Fatalf("stream ended: %v", lines.Err())
+		}
+		return lines.Text()
+	}
+
+	// Wait until subscribed.
+	if got, want := next(), ": connected"; got != want {
+		t.Fatalf("first line = %q, want %q", got, want)
+	}
+	next()
+
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hub.Publish(&data.Point{Address: "BB:BB:BB:BB:BB:BB", Timestamp: ts, Temperature: data.Ptr(1.0)})
+	hub.Publish(&data.Point{Address: "AA:AA:AA:AA:AA:AA", Timestamp: ts, Battery: data.Ptr(3000.0)})
+	hub.Publish(&data.Point{Address: "AA:AA:AA:AA:AA:AA", Timestamp: ts, Temperature: data.Ptr(21.5), Battery: data.Ptr(3000.0)})
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		got = append(got, next())
+	}
+	want := []string{
+		"event: point",
+		`data: {"Address":"AA:AA:AA:AA:AA:AA","Timestamp":"2024-01-01T00:00:00Z","Temperature":21.5}`,
+		"",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("stream diff -want +got\n%v", diff)
+	}
+}
+
+func TestEventHubDrops(t *testing.T) {
+	hub := &EventHub{}
+	s := hub.subscribe()
+	defer hub.unsubscribe(s)
+
+	for i := 0; i < eventsBufferLen+3; i++ {
+		hub.Publish(&data.Point{})
+	}
+	if got, want := hub.takeDropped(s), uint64(3); got != want {
+		t.Errorf("dropped = %d, want %d", got, want)
+	}
+	if got, want := hub.takeDropped(s), uint64(0); got != want {
+		t.Errorf("dropped after take = %d, want %d", got, want)
+	}
+}
+
+func TestEventsHandlerBadKind(t *testing.T) {
+	w := httptest.NewRecorder()
+	EventsHandler(&EventsHandlerOpts{Hub: &EventHub{}, HeartbeatInterval: time.Hour})(w, httptest.NewRequest(http.MethodGet, "/events?kind=nope", strings.NewReader("")))
+	if got, want := w.Code, http.StatusBadRequest; got != want {
+		t.Errorf("status = %d, want %d", got, want)
+	}
+}
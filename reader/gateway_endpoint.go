@@ -0,0 +1,150 @@
+package reader
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+	"github.com/s5i/ruuvi2db/reader/protocol"
+)
+
+type RunGatewayEndpointOpts struct {
+	Listen      string
+	Format8Keys map[string][]byte
+	CachePointF func(*data.Point)
+	TagTracker  *TagTracker
+}
+
+// RunGatewayEndpoint accepts data pushed by Ruuvi Gateways over HTTP.
+func RunGatewayEndpoint(ctx context.Context, opts *RunGatewayEndpointOpts) error {
+	srv := http.Server{}
+	srv.Addr = opts.Listen
+
+	srv.ReadTimeout = time.Minute
+	srv.WriteTimeout = time.Minute
+	srv.SetKeepAlivesEnabled(false)
+
+	mux := http.NewServeMux()
+
+	mux.Handle("/gateway", GatewayHandler(&GatewayHandlerOpts{
+		Format8Keys: opts.Format8Keys,
+		CachePointF: opts.CachePointF,
+		TagTracker:  opts.TagTracker,
+	}))
+
+	srv.Handler = mux
+
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(ctx)
+	}()
+
+	switch err := srv.ListenAndServe(); {
+	case errors.Is(err, http.ErrServerClosed):
+		return nil
+	default:
+		return err
+	}
+}
+
+type GatewayHandlerOpts struct {
+	Format8Keys map[string][]byte
+	CachePointF func(*data.Point)
+	TagTracker  *TagTracker
+}
+
+// GatewayHandler handles the JSON format of the Ruuvi Gateway "HTTP(S) custom server" option.
+// https://docs.ruuvi.com/gw-data-formats
+func GatewayHandler(opts *GatewayHandlerOpts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req gatewayRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("malformed request: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		for mac, tag := range req.Data.Tags {
+			p, err := parseGatewayTag(mac, tag, &protocol.ParseOpts{
+				Format8Keys: opts.Format8Keys,
+			}, opts.TagTracker)
+			if err != nil {
+				continue
+			}
+			opts.CachePointF(p)
+		}
+	}
+}
+
+type gatewayRequest struct {
+	Data struct {
+		Tags map[string]gatewayTag `json:"tags"`
+	} `json:"data"`
+}
+
+type gatewayTag struct {
+	RSSI      int              `json:"rssi"`
+	Timestamp gatewayTimestamp `json:"timestamp"`
+	Data      string           `json:"data"`
+}
+
+// gatewayTimestamp is a Unix timestamp, sent either as a string or as a number depending on firmware version.
+type gatewayTimestamp int64
+
+func (ts *gatewayTimestamp) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*ts = 0
+		return nil
+	}
+
+	x, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed timestamp %s", b)
+	}
+	*ts = gatewayTimestamp(x)
+	return nil
+}
+
+// parseGatewayTag decodes the raw advertisement relayed by the gateway, and records it with tags (if set).
+// The gateway's timestamp is used if present, since data is pushed in batches.
+func parseGatewayTag(mac string, tag gatewayTag, opts *protocol.ParseOpts, tags *TagTracker) (*data.Point, error) {
+	raw, err := hex.DecodeString(tag.Data)
+	if err != nil {
+		return nil, fmt.Errorf("malformed data for %s: %v", mac, err)
+	}
+
+	a, err := bluetooth.ParseAdvertisingData(raw)
+	if err != nil {
+		return nil, fmt.Errorf("malformed data for %s: %v", mac, err)
+	}
+	if a == nil {
+		return nil, fmt.Errorf("no data for %s", mac)
+	}
+	a.Addr = mac
+	a.RSSI = tag.RSSI
+	if tag.Timestamp > 0 {
+		a.Timestamp = time.Unix(int64(tag.Timestamp), 0)
+	}
+
+	p, err := parseAdvertisement(a, opts)
+	if tags != nil {
+		tags.Observe(a, p, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,85 @@
+package reader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func TestGatewayHandler(t *testing.T) {
+	var got []*data.Point
+	h := GatewayHandler(&GatewayHandlerOpts{
+		CachePointF: func(p *data.Point) { got = append(got, p) },
+	})
+
+	body := `{
+  "data": {
+    "coordinates": "",
+    "timestamp": "1700000010",
+    "gw_mac": "C8:25:2D:8E:9C:2C",
+    "tags": {
+      "CB:B8:33:4C:88:4F": {
+        "rssi": -61,
+        "timestamp": 1700000000,
+        "data": "0201061BFF99040512FC5394C37C0004FFFC040CAC364200CDCBB8334C884F"
+      },
+      "AA:AA:AA:AA:AA:AA": {
+        "rssi": -70,
+        "timestamp": "1700000005",
+        "data": "not hex"
+      }
+    }
+  }
+}`
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/gateway", strings.NewReader(body)))
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("status = %d, want %d (%s)", got, want, w.Body)
+	}
+
+	want := []*data.Point{
+		{
+			Address:             "CB:B8:33:4C:88:4F",
+			Timestamp:           time.Unix(1700000000, 0),
+			Temperature:         data.Ptr(24.3),
+			Humidity:            data.Ptr(53.49),
+			Pressure:            data.Ptr(1000.44),
+			Battery:             data.Ptr[float64](2977),
+			AccelerationX:       data.Ptr(0.004),
+			AccelerationY:       data.Ptr(-0.004),
+			AccelerationZ:       data.Ptr(1.036),
+			MovementCounter:     data.Ptr[uint32](66),
+			MeasurementSequence: data.Ptr[uint32](205),
+			RSSI:                data.Ptr(-61),
+			TxPower:             data.Ptr(4),
+		},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(0, 0.001)); diff != "" {
+		t.Errorf("cached points diff -want +got\n%v", diff)
+	}
+}
+
+func TestGatewayHandlerMalformed(t *testing.T) {
+	h := GatewayHandler(&GatewayHandlerOpts{
+		CachePointF: func(p *data.Point) {},
+	})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/gateway", strings.NewReader(`{"data": {"tags": [}`)))
+	if got, want := w.Code, http.StatusBadRequest; got != want {
+		t.Errorf("status = %d, want %d", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/gateway", nil))
+	if got, want := w.Code, http.StatusMethodNotAllowed; got != want {
+		t.Errorf("status = %d, want %d", got, want)
+	}
+}
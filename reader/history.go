@@ -0,0 +1,192 @@
+package reader
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/s5i/ruuvi2db/data"
+)
+
+var (
+	ErrHistory = fmt.Errorf("history error")
+)
+
+type HistoryOpts struct {
+	// Points older than MaxAge are dropped; zero means no age limit.
+	MaxAge time.Duration
+	// At most MaxPoints are kept per tag; zero means no count limit.
+	MaxPoints int
+}
+
+// History keeps recent points of every tag, so that consumers can catch up after an outage.
+type History struct {
+	opts *HistoryOpts
+
+	mu   sync.Mutex
+	tags map[string][]*data.Point
+}
+
+func NewHistory(opts *HistoryOpts) *History {
+	return &History{
+		opts: opts,
+		tags: map[string][]*data.Point{},
+	}
+}
+
+// Put adds a point.
+func (h *History) Put(p *data.Point) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Points mostly arrive in order; sources with their own timestamps (e.g. gateways) may lag behind.
+	pts := h.tags[p.Address]
+	i := sort.Search(len(pts), func(i int) bool { return pts[i].Timestamp.After(p.Timestamp) })
+	pts = slices.Insert(pts, i, p)
+
+	h.tags[p.Address] = h.trim(pts, time.Now())
+}
+
+// Since returns points newer than ts, oldest first.
+func (h *History) Since(ts time.Time) []*data.Point {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+
+	var ret []*data.Point
+	for addr, pts := range h.tags {
+		pts = h.trim(pts, now)
+		if len(pts) == 0 {
+			delete(h.tags, addr)
+			continue
+		}
+		h.tags[addr] = pts
+
+		i := sort.Search(len(pts), func(i int) bool { return pts[i].Timestamp.After(ts) })
+		ret = append(ret, pts[i:]...)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Timestamp.Before(ret[j].Timestamp) })
+	return ret
+}
+
+func (h *History) trim(pts []*data.Point, now time.Time) []*data.Point {
+	if n := len(pts) - h.opts.MaxPoints; h.opts.MaxPoints > 0 && n > 0 {
+		pts = pts[n:]
+	}
+	if h.opts.MaxAge > 0 {
+		cutoff := now.Add(-h.opts.MaxAge)
+		i := sort.Search(len(pts), func(i int) bool { return pts[i].Timestamp.After(cutoff) })
+		pts = pts[i:]
+	}
+	// Let go of the dropped points once the slice has shrunk enough.
+	if cap(pts) > 64 && len(pts) < cap(pts)/4 {
+		pts = append([]*data.Point(nil), pts...)
+	}
+	return pts
+}
+
+type RunHistoryOpts struct {
+	History *History
+	// Path is the file the history is saved to every SavePeriod and on shutdown.
+	Path       string
+	SavePeriod time.Duration
+}
+
+// RunHistory persists the history; see History.Load.
+func RunHistory(ctx context.Context, opts *RunHistoryOpts) error {
+	tick := time.NewTicker(opts.SavePeriod)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := opts.History.save(opts.Path); err != nil {
+				return fmt.Errorf("%w: %v", ErrHistory, err)
+			}
+			return nil
+		case <-tick.C:
+			if err := opts.History.save(opts.Path); err != nil {
+				log.Printf("%v: %v", ErrHistory, err)
+			}
+		}
+	}
+}
+
+// The history file is a sequence of length-prefixed encoded points.
+
+func (h *History) save(path string) error {
+	pts := h.Since(time.Time{})
+
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, p := range pts {
+		b, err := p.Encode()
+		if err != nil {
+			continue
+		}
+		if err := binary.Write(w, binary.BigEndian, uint16(len(b))); err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// Load adds points saved by RunHistory. A missing file isn't an error.
+func (h *History) Load(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrHistory, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		var l uint16
+		switch err := binary.Read(r, binary.BigEndian, &l); {
+		case errors.Is(err, io.EOF):
+			return nil
+		case err != nil:
+			return fmt.Errorf("%w: %v", ErrHistory, err)
+		}
+
+		b := make([]byte, l)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return fmt.Errorf("%w: %v", ErrHistory, err)
+		}
+
+		p, err := data.DecodePoint(b)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrHistory, err)
+		}
+		h.Put(p)
+	}
+}
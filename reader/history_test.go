@@ -0,0 +1,52 @@
+package reader
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func TestHistory(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	p := func(addr string, age time.Duration) *data.Point {
+		return &data.Point{Address: addr, Timestamp: now.Add(-age), Temperature: data.Ptr(age.Minutes())}
+	}
+
+	h := NewHistory(&HistoryOpts{MaxAge: time.Hour, MaxPoints: 3})
+	h.Put(p("AA:AA:AA:AA:AA:AA", 2*time.Hour)) // Too old.
+	for _, age := range []time.Duration{40, 30, 20, 10} {
+		h.Put(p("AA:AA:AA:AA:AA:AA", age*time.Minute)) // The oldest one exceeds MaxPoints.
+	}
+	h.Put(p("BB:BB:BB:BB:BB:BB", 5*time.Minute))
+	h.Put(p("BB:BB:BB:BB:BB:BB", 25*time.Minute)) // Out of order.
+
+	want := []*data.Point{
+		p("BB:BB:BB:BB:BB:BB", 25*time.Minute),
+		p("AA:AA:AA:AA:AA:AA", 20*time.Minute),
+		p("AA:AA:AA:AA:AA:AA", 10*time.Minute),
+		p("BB:BB:BB:BB:BB:BB", 5*time.Minute),
+	}
+	if diff := cmp.Diff(want, h.Since(now.Add(-30*time.Minute))); diff != "" {
+		t.Errorf("Since diff -want +got\n%v", diff)
+	}
+
+	// Persisted history survives a restart.
+	path := filepath.Join(t.TempDir(), "history")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := RunHistory(ctx, &RunHistoryOpts{History: h, Path: path, SavePeriod: time.Hour}); err != nil {
+		t.Fatalf("RunHistory failed: %v", err)
+	}
+
+	loaded := NewHistory(&HistoryOpts{MaxAge: time.Hour})
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if diff := cmp.Diff(h.Since(time.Time{}), loaded.Since(time.Time{}), cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+		t.Errorf("loaded history diff -want +got\n%v", diff)
+	}
+}
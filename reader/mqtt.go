@@ -0,0 +1,28 @@
+package reader
+
+import (
+	"context"
+	"errors"
+
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+	"github.com/s5i/ruuvi2db/reader/mqtt"
+)
+
+type RunMQTTOpts struct {
+	Config      *mqtt.Config
+	Format8Keys map[string][]byte
+	CachePointF func(*data.Point)
+	TagTracker  *TagTracker
+}
+
+func RunMQTT(ctx context.Context, opts *RunMQTTOpts) error {
+	switch err := mqtt.Run(ctx, func(a *bluetooth.Advertisement) {
+		handleAdvertisement(a, opts.Format8Keys, opts.TagTracker, opts.CachePointF)
+	}, opts.Config); {
+	case errors.Is(err, context.Canceled):
+		return nil
+	default:
+		return err
+	}
+}
@@ -0,0 +1,141 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+)
+
+var (
+	ErrConfig  = fmt.Errorf("mqtt config error")
+	ErrConnect = fmt.Errorf("mqtt connect error")
+)
+
+// Config contains options for the MQTT subscriber.
+type Config struct {
+	// Broker is the broker URL, e.g. tcp://localhost:1883 or ssl://broker:8883.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// Topics are the topic filters to subscribe to, e.g. ruuvi/#.
+	Topics []string
+
+	// TLS options; TLS is also used if the broker URL has an ssl, tls or mqtts scheme.
+	TLS                bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// Run subscribes to advertisements relayed over MQTT and feeds them to callback, the same way bluetooth.Run does.
+// Lost connections are re-established in the background until ctx is done.
+func Run(ctx context.Context, callback func(*bluetooth.Advertisement), cfg *Config) error {
+	u, err := url.Parse(cfg.Broker)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: malformed broker %q", ErrConfig, cfg.Broker)
+	}
+	if len(cfg.Topics) == 0 {
+		return fmt.Errorf("%w: no topics", ErrConfig)
+	}
+
+	opts := paho.NewClientOptions()
+	opts.AddBroker(cfg.Broker)
+	opts.SetClientID(cfg.ClientID)
+	opts.SetUsername(cfg.Username)
+	opts.SetPassword(cfg.Password)
+	opts.SetCleanSession(true)
+	opts.SetAutoReconnect(true)
+	opts.SetConnectRetry(true)
+	opts.SetConnectRetryInterval(10 * time.Second)
+	opts.SetMaxReconnectInterval(time.Minute)
+
+	useTLS := cfg.TLS
+	switch u.Scheme {
+	case "ssl", "tls", "mqtts":
+		useTLS = true
+	}
+	if useTLS {
+		tlsCfg, err := tlsConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrConfig, err)
+		}
+		opts.SetTLSConfig(tlsCfg)
+	}
+
+	filters := map[string]byte{}
+	for _, t := range cfg.Topics {
+		filters[t] = 0
+	}
+
+	handler := func(_ paho.Client, msg paho.Message) {
+		a, err := decodeMessage(msg.Topic(), msg.Payload())
+		if err != nil {
+			return
+		}
+		callback(a)
+	}
+
+	// Subscriptions don't survive reconnects with a clean session, so they're (re)made on every connect.
+	opts.SetOnConnectHandler(func(c paho.Client) {
+		if tok := c.SubscribeMultiple(filters, handler); tok.Wait() && tok.Error() != nil {
+			log.Printf("mqtt subscribe failed: %v", tok.Error())
+		}
+	})
+	opts.SetConnectionLostHandler(func(_ paho.Client, err error) {
+		log.Printf("mqtt connection lost: %v", err)
+	})
+
+	c := paho.NewClient(opts)
+	defer c.Disconnect(250)
+
+	// With connect retry enabled, the token only completes once connected (or on a non-retryable error).
+	tok := c.Connect()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-tok.Done():
+		if err := tok.Error(); err != nil {
+			return fmt.Errorf("%w: %v", ErrConnect, err)
+		}
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func tlsConfig(cfg *Config) (*tls.Config, error) {
+	ret := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		b, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		ret.RootCAs = x509.NewCertPool()
+		if !ret.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates in %q", cfg.CAFile)
+		}
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		ret.Certificates = []tls.Certificate{cert}
+	}
+
+	return ret, nil
+}
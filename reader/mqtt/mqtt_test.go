@@ -0,0 +1,216 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+)
+
+var (
+	df5 = mustHex("0512FC5394C37C0004FFFC040CAC364200CDCBB8334C884F")
+
+	wantAdv = &bluetooth.Advertisement{
+		Addr:             "cb:b8:33:4c:88:4f",
+		RSSI:             -61,
+		Timestamp:        time.Unix(1700000000, 0),
+		ManufacturerID:   0x0499,
+		ManufacturerData: df5,
+	}
+)
+
+const gwPayload = `{"gw_mac":"C8:25:2D:8E:9C:2C","rssi":-61,"aoa":[],"gwts":"1700000010","ts":"1700000000","data":"0201061BFF99040512FC5394C37C0004FFFC040CAC364200CDCBB8334C884F","coords":""}`
+
+func TestDecodeMessage(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		topic   string
+		payload string
+		want    *bluetooth.Advertisement
+		wantErr bool
+	}{
+		{
+			name:    "gateway json",
+			topic:   "ruuvi/C8:25:2D:8E:9C:2C/CB:B8:33:4C:88:4F",
+			payload: gwPayload,
+			want:    wantAdv,
+		},
+		{
+			name:    "numeric timestamp",
+			topic:   "ruuvi/C8:25:2D:8E:9C:2C/CB:B8:33:4C:88:4F",
+			payload: `{"rssi":-61,"ts":1700000000,"data":"1BFF99040512FC5394C37C0004FFFC040CAC364200CDCBB8334C884F"}`,
+			want:    wantAdv,
+		},
+		{
+			name:    "no timestamp",
+			topic:   "ruuvi/C8:25:2D:8E:9C:2C/CB:B8:33:4C:88:4F",
+			payload: `{"rssi":-61,"data":"1BFF99040512FC5394C37C0004FFFC040CAC364200CDCBB8334C884F"}`,
+			want:    &bluetooth.Advertisement{Addr: wantAdv.Addr, RSSI: -61, ManufacturerID: 0x0499, ManufacturerData: df5},
+		},
+		{
+			name:    "raw hex",
+			topic:   "esphome/proxy/cb:b8:33:4c:88:4f",
+			payload: "1BFF99040512FC5394C37C0004FFFC040CAC364200CDCBB8334C884F\n",
+			want:    &bluetooth.Advertisement{Addr: wantAdv.Addr, ManufacturerID: 0x0499, ManufacturerData: df5},
+		},
+		{
+			name:    "gateway status topic",
+			topic:   "ruuvi/C8:25:2D:8E:9C:2C/gw_status",
+			payload: `{"state":"online"}`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed data",
+			topic:   "ruuvi/C8:25:2D:8E:9C:2C/CB:B8:33:4C:88:4F",
+			payload: `{"rssi":-61,"data":"not hex"}`,
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := decodeMessage(tc.topic, []byte(tc.payload))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("decodeMessage err = %v, want error: %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("decodeMessage diff -want +got\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	topics := make(chan []string, 1)
+	go fakeBroker(t, l, topics, map[string]string{
+		"ruuvi/C8:25:2D:8E:9C:2C/CB:B8:33:4C:88:4F": gwPayload,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var got []*bluetooth.Advertisement
+	err = Run(ctx, func(a *bluetooth.Advertisement) {
+		got = append(got, a)
+		cancel()
+	}, &Config{
+		Broker:   "tcp://" + l.Addr().String(),
+		ClientID: "test",
+		Topics:   []string{"ruuvi/#"},
+	})
+	if err != context.Canceled {
+		t.Errorf("Run = %v, want %v", err, context.Canceled)
+	}
+
+	if diff := cmp.Diff([]string{"ruuvi/#"}, <-topics); diff != "" {
+		t.Errorf("subscribed topics diff -want +got\n%v", diff)
+	}
+	if diff := cmp.Diff([]*bluetooth.Advertisement{wantAdv}, got); diff != "" {
+		t.Errorf("Run diff -want +got\n%v", diff)
+	}
+}
+
+// fakeBroker speaks just enough MQTT 3.1.1 to accept a single client, report its subscriptions
+// and publish the given messages to it.
+func fakeBroker(t *testing.T, l net.Listener, topics chan<- []string, msgs map[string]string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for {
+		typ, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+
+		switch typ {
+		case 1: // CONNECT
+			conn.Write([]byte{0x20, 2, 0, 0})
+		case 8: // SUBSCRIBE
+			var filters []string
+			for rest := body[2:]; len(rest) >= 2; {
+				n := int(binary.BigEndian.Uint16(rest))
+				filters = append(filters, string(rest[2:2+n]))
+				rest = rest[2+n+1:]
+			}
+			topics <- filters
+
+			ack := append([]byte{0x90, byte(2 + len(filters)), body[0], body[1]}, make([]byte, len(filters))...)
+			conn.Write(ack)
+
+			for topic, payload := range msgs {
+				pkt := binary.BigEndian.AppendUint16(nil, uint16(len(topic)))
+				pkt = append(pkt, topic...)
+				pkt = append(pkt, payload...)
+				conn.Write(append(append([]byte{0x30}, remainingLength(len(pkt))...), pkt...))
+			}
+		case 12: // PINGREQ
+			conn.Write([]byte{0xD0, 0})
+		case 14: // DISCONNECT
+			return
+		}
+	}
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	hdr, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var n, shift int
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(b&0x7F) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return hdr >> 4, body, nil
+}
+
+func remainingLength(n int) []byte {
+	var ret []byte
+	for {
+		b := byte(n & 0x7F)
+		n >>= 7
+		if n > 0 {
+			b |= 0x80
+		}
+		ret = append(ret, b)
+		if n == 0 {
+			return ret
+		}
+	}
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,62 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+)
+
+// gatewayMessage is the JSON published by the Ruuvi Gateway (and ESP32 proxies mimicking it) to ruuvi/<gw>/<mac>.
+// https://docs.ruuvi.com/gw-data-formats/mqtt-time-stamped-data-from-bluetooth-sensors
+type gatewayMessage struct {
+	RSSI int    `json:"rssi"`
+	Data string `json:"data"`
+	// TS is the Unix time the gateway received the advertisement; sent as a string or a number
+	// depending on firmware version.
+	TS json.Number `json:"ts"`
+}
+
+// decodeMessage converts an MQTT message into an advertisement.
+// The payload is either the gateway JSON or the raw advertising data as a hex string.
+// The tag address is the last level of the topic.
+func decodeMessage(topic string, payload []byte) (*bluetooth.Advertisement, error) {
+	mac, err := net.ParseMAC(topic[strings.LastIndex(topic, "/")+1:])
+	if err != nil {
+		return nil, fmt.Errorf("no address in topic %q", topic)
+	}
+
+	var msg gatewayMessage
+	payload = bytes.TrimSpace(payload)
+	if bytes.HasPrefix(payload, []byte("{")) {
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return nil, fmt.Errorf("malformed payload on %q: %v", topic, err)
+		}
+	} else {
+		msg.Data = string(payload)
+	}
+
+	raw, err := hex.DecodeString(msg.Data)
+	if err != nil {
+		return nil, fmt.Errorf("malformed data on %q: %v", topic, err)
+	}
+
+	a, err := bluetooth.ParseAdvertisingData(raw)
+	if err != nil {
+		return nil, fmt.Errorf("malformed data on %q: %v", topic, err)
+	}
+	if a == nil {
+		return nil, fmt.Errorf("no data on %q", topic)
+	}
+	a.Addr = mac.String()
+	a.RSSI = msg.RSSI
+	if ts, err := msg.TS.Int64(); err == nil && ts > 0 {
+		a.Timestamp = time.Unix(ts, 0)
+	}
+	return a, nil
+}
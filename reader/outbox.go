@@ -0,0 +1,147 @@
+package reader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/s5i/ruuvi2db/data"
+)
+
+// outbox queues batches until they're delivered, oldest first.
+// Batches are kept as files in dir, so that they survive restarts; if dir is empty, they're kept in memory.
+// Once maxBatches is reached, the oldest batch is dropped to make room.
+type outbox struct {
+	dir        string
+	maxBatches int
+
+	mem []*data.Batch
+}
+
+const outboxExt = ".json"
+
+func newOutbox(dir string, maxBatches int) (*outbox, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create outbox: %v", err)
+		}
+	}
+	return &outbox{dir: dir, maxBatches: maxBatches}, nil
+}
+
+// add queues a batch. It returns the number of batches dropped to stay within bounds.
+// Batch IDs must sort in the order batches are added.
+func (o *outbox) add(b *data.Batch) (dropped int, err error) {
+	if o.dir == "" {
+		o.mem = append(o.mem, b)
+		if n := len(o.mem) - o.maxBatches; o.maxBatches > 0 && n > 0 {
+			o.mem = o.mem[n:]
+			dropped = n
+		}
+		return dropped, nil
+	}
+
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return 0, err
+	}
+
+	// Write to a temporary file first, so that a crash doesn't leave a truncated batch behind.
+	path := filepath.Join(o.dir, b.ID+outboxExt)
+	if err := os.WriteFile(path+".tmp", raw, 0644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return 0, err
+	}
+
+	ids, err := o.ids()
+	if err != nil {
+		return 0, err
+	}
+	if n := len(ids) - o.maxBatches; o.maxBatches > 0 && n > 0 {
+		for _, id := range ids[:n] {
+			if err := o.remove(id); err != nil {
+				return dropped, err
+			}
+			dropped++
+		}
+	}
+	return dropped, nil
+}
+
+// oldest returns the oldest queued batch, or nil if there's none.
+// Unreadable batch files are removed.
+func (o *outbox) oldest() (*data.Batch, error) {
+	if o.dir == "" {
+		if len(o.mem) == 0 {
+			return nil, nil
+		}
+		return o.mem[0], nil
+	}
+
+	ids, err := o.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		raw, err := os.ReadFile(filepath.Join(o.dir, id+outboxExt))
+		if err != nil {
+			return nil, err
+		}
+
+		b := &data.Batch{}
+		if err := json.Unmarshal(raw, b); err != nil {
+			if err := o.remove(id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return b, nil
+	}
+	return nil, nil
+}
+
+func (o *outbox) remove(id string) error {
+	if o.dir == "" {
+		if len(o.mem) > 0 && o.mem[0].ID == id {
+			o.mem = o.mem[1:]
+		}
+		return nil
+	}
+
+	if err := os.Remove(filepath.Join(o.dir, id+outboxExt)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (o *outbox) len() (int, error) {
+	if o.dir == "" {
+		return len(o.mem), nil
+	}
+
+	ids, err := o.ids()
+	return len(ids), err
+}
+
+// ids returns the IDs of batch files in the outbox, oldest first.
+func (o *outbox) ids() ([]string, error) {
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), outboxExt); ok && e.Type().IsRegular() {
+			ret = append(ret, id)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
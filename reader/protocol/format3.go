@@ -8,6 +8,12 @@ import (
 	"github.com/s5i/ruuvi2db/data"
 )
 
+func init() {
+	RegisterManufacturerData("format3", 0x0499, 3, func(mfID uint16, raw []byte, _ string, _ *ParseOpts) (*data.Point, error) {
+		return parseFormat3(mfID, raw)
+	})
+}
+
 type format3 struct {
 	DataFormat uint8
 	Humid      uint8
@@ -48,9 +54,13 @@ func parseFormat3(mfID uint16, raw []byte) (*data.Point, error) {
 	}
 
 	return &data.Point{
-		Temperature: temp(packet.Temp, packet.TempFrac),
-		Humidity:    float64(packet.Humid) / 2.0,
-		Pressure:    (float64(packet.Pres) + 50000.0) / 100.0,
-		Battery:     float64(packet.Batt),
+		Temperature: data.Ptr(temp(packet.Temp, packet.TempFrac)),
+		Humidity:    data.Ptr(float64(packet.Humid) / 2.0),
+		Pressure:    data.Ptr((float64(packet.Pres) + 50000.0) / 100.0),
+		Battery:     data.Ptr(float64(packet.Batt)),
+
+		AccelerationX: data.Ptr(float64(packet.AccX) / 1000.0),
+		AccelerationY: data.Ptr(float64(packet.AccY) / 1000.0),
+		AccelerationZ: data.Ptr(float64(packet.AccZ) / 1000.0),
 	}, nil
 }
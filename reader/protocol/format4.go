@@ -0,0 +1,129 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func init() {
+	// Both formats are carried in Eddystone-URL frames; the format byte is only known after decoding the URL.
+	RegisterServiceData("format2/format4", 0xFEAA, func(uuid uint16, raw []byte, _ string, _ *ParseOpts) (*data.Point, error) {
+		p, err2 := parseFormat2(uuid, raw)
+		if err2 == nil {
+			return p, nil
+		}
+		p, err4 := parseFormat4(uuid, raw)
+		if err4 == nil {
+			return p, nil
+		}
+		return nil, errors.Join(err2, err4)
+	})
+}
+
+type format4 struct {
+	DataFormat uint8
+	Humid      uint8
+	Temp       uint8
+	TempFrac   uint8
+	Pres       uint16
+}
+
+// https://github.com/ruuvi/ruuvi-sensor-protocols/blob/master/dataformat_02.md
+func parseFormat2(uuid uint16, raw []byte) (*data.Point, error) {
+	return parseURLFormat(uuid, raw, 2)
+}
+
+// https://github.com/ruuvi/ruuvi-sensor-protocols/blob/master/dataformat_04.md
+func parseFormat4(uuid uint16, raw []byte) (*data.Point, error) {
+	return parseURLFormat(uuid, raw, 4)
+}
+
+// parseURLFormat decodes Eddystone-URL frames pointing at ruu.vi/#<base64 payload>.
+// Format 4 appends a single tag ID character to the payload, which is ignored.
+func parseURLFormat(uuid uint16, raw []byte, wantFormat int) (*data.Point, error) {
+	if gotUUID, wantUUID := uuid, uint16(0xFEAA); gotUUID != wantUUID {
+		return nil, fmt.Errorf("service UUID mismatch (got %X, want %X)", gotUUID, wantUUID)
+	}
+
+	url, err := eddystoneURL(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, ok := strings.CutPrefix(url, "https://ruu.vi/#")
+	if !ok {
+		return nil, fmt.Errorf("unexpected URL %q", url)
+	}
+	if gotLen, wantLen := len(encoded), 8; gotLen < wantLen {
+		return nil, fmt.Errorf("payload length mismatch (got %d, want at least %d)", gotLen, wantLen)
+	}
+
+	// The payload has been seen both in standard and URL-safe alphabets.
+	encoded = strings.NewReplacer("+", "-", "/", "_").Replace(encoded[0:8])
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("base64 decoding failed: %v", err)
+	}
+
+	if gotFormat := int(payload[0]); gotFormat != wantFormat {
+		return nil, fmt.Errorf("format mismatch (got %d, want %d)", gotFormat, wantFormat)
+	}
+
+	var packet format4
+	if err := binary.Read(bytes.NewReader(payload), binary.BigEndian, &packet); err != nil {
+		return nil, fmt.Errorf("binary.Read failed: %v", err)
+	}
+
+	temp := func(t uint8, f uint8) float64 {
+		sign := float64(1)
+		if (t & (1 << 7)) > 0 {
+			sign = float64(-1)
+		}
+		t &^= 1 << 7
+
+		return (float64(t) + float64(f)/100.0) * sign
+	}
+
+	return &data.Point{
+		Temperature: data.Ptr(temp(packet.Temp, packet.TempFrac)),
+		Humidity:    data.Ptr(float64(packet.Humid) / 2.0),
+		Pressure:    data.Ptr((float64(packet.Pres) + 50000.0) / 100.0),
+	}, nil
+}
+
+// https://github.com/google/eddystone/tree/master/eddystone-url
+func eddystoneURL(raw []byte) (string, error) {
+	if gotLen, wantLen := len(raw), 3; gotLen < wantLen {
+		return "", fmt.Errorf("frame length mismatch (got %d, want at least %d)", gotLen, wantLen)
+	}
+	if gotFrame, wantFrame := raw[0], uint8(0x10); gotFrame != wantFrame {
+		return "", fmt.Errorf("frame type mismatch (got %X, want %X)", gotFrame, wantFrame)
+	}
+
+	schemes := []string{"http://www.", "https://www.", "http://", "https://"}
+	expansions := []string{".com/", ".org/", ".edu/", ".net/", ".info/", ".biz/", ".gov/", ".com", ".org", ".edu", ".net", ".info", ".biz", ".gov"}
+
+	if int(raw[2]) >= len(schemes) {
+		return "", fmt.Errorf("unknown URL scheme %X", raw[2])
+	}
+
+	var sb strings.Builder
+	sb.WriteString(schemes[raw[2]])
+	for _, c := range raw[3:] {
+		switch {
+		case int(c) < len(expansions):
+			sb.WriteString(expansions[c])
+		case c <= 0x20 || c >= 0x7F:
+			return "", fmt.Errorf("invalid URL character %X", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), nil
+}
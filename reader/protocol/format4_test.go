@@ -0,0 +1,126 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func TestParseServiceData(t *testing.T) {
+	frame := func(scheme byte, url string) []byte {
+		return append([]byte{0x10, 0xEE, scheme}, url...)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		uuid    uint16
+		raw     []byte
+		want    *data.Point
+		wantErr bool
+	}{
+		{
+			name: "format 2",
+			uuid: 0xFEAA,
+			raw:  frame(0x03, "ruu.vi/#AjwYAMFc"),
+			want: &data.Point{
+				Address:     "AA:BB:CC:DD:EE:FF",
+				Temperature: data.Ptr[float64](24),
+				Humidity:    data.Ptr[float64](30),
+				Pressure:    data.Ptr[float64](995),
+			},
+		},
+		{
+			name: "format 4",
+			uuid: 0xFEAA,
+			raw:  frame(0x03, "ruu.vi/#BEAXAMBAs"),
+			want: &data.Point{
+				Address:     "AA:BB:CC:DD:EE:FF",
+				Temperature: data.Ptr[float64](23),
+				Humidity:    data.Ptr[float64](32),
+				Pressure:    data.Ptr[float64](992.16),
+			},
+		},
+		// The fraction byte holds hundredths of a degree (0-99), as in format 3.
+		{
+			name: "temperature fraction",
+			uuid: 0xFEAA,
+			raw:  frame(0x03, "ruu.vi/#BEAXLcBAs"), // 04 40 17 2D C0 40
+			want: &data.Point{
+				Address:     "AA:BB:CC:DD:EE:FF",
+				Temperature: data.Ptr(23.45),
+				Humidity:    data.Ptr[float64](32),
+				Pressure:    data.Ptr[float64](992.16),
+			},
+		},
+		{
+			name: "negative temperature",
+			uuid: 0xFEAA,
+			raw:  frame(0x03, "ruu.vi/#BECFMsBAs"), // 04 40 85 32 C0 40
+			want: &data.Point{
+				Address:     "AA:BB:CC:DD:EE:FF",
+				Temperature: data.Ptr(-5.5),
+				Humidity:    data.Ptr[float64](32),
+				Pressure:    data.Ptr[float64](992.16),
+			},
+		},
+		{
+			name: "format 2 negative temperature",
+			uuid: 0xFEAA,
+			raw:  frame(0x03, "ruu.vi/#AjyYY8Fc"), // 02 3C 98 63 C1 5C
+			want: &data.Point{
+				Address:     "AA:BB:CC:DD:EE:FF",
+				Temperature: data.Ptr(-24.99),
+				Humidity:    data.Ptr[float64](30),
+				Pressure:    data.Ptr[float64](995),
+			},
+		},
+		{
+			name:    "wrong UUID",
+			uuid:    0xFEAB,
+			raw:     frame(0x03, "ruu.vi/#BEAXAMBAs"),
+			wantErr: true,
+		},
+		{
+			name:    "wrong frame type",
+			uuid:    0xFEAA,
+			raw:     append([]byte{0x00, 0xEE, 0x03}, "ruu.vi/#BEAXAMBAs"...),
+			wantErr: true,
+		},
+		{
+			name:    "foreign URL",
+			uuid:    0xFEAA,
+			raw:     frame(0x03, "example.com/#BEAXAMBAs"),
+			wantErr: true,
+		},
+		{
+			name:    "plain http",
+			uuid:    0xFEAA,
+			raw:     frame(0x02, "ruu.vi/#BEAXAMBAs"),
+			wantErr: true,
+		},
+		{
+			name:    "unsupported format",
+			uuid:    0xFEAA,
+			raw:     frame(0x03, "ruu.vi/#A0AXAMBA"),
+			wantErr: true,
+		},
+		{
+			name:    "truncated payload",
+			uuid:    0xFEAA,
+			raw:     frame(0x03, "ruu.vi/#BEAX"),
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseServiceData(tc.uuid, tc.raw, "aa:bb:cc:dd:ee:ff", nil)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ParseServiceData err = %v, want error: %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateApprox(0, 0.001), cmpopts.IgnoreFields(data.Point{}, "Timestamp")); diff != "" {
+				t.Errorf("ParseServiceData diff -want +got\n%v", diff)
+			}
+		})
+	}
+}
@@ -4,10 +4,17 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math"
 
 	"github.com/s5i/ruuvi2db/data"
 )
 
+func init() {
+	RegisterManufacturerData("format5", 0x0499, 5, func(mfID uint16, raw []byte, _ string, _ *ParseOpts) (*data.Point, error) {
+		return parseFormat5(mfID, raw)
+	})
+}
+
 type format5 struct {
 	DataFormat uint8
 	Temp       int16
@@ -39,10 +46,20 @@ func parseFormat5(mfID uint16, raw []byte) (*data.Point, error) {
 		return nil, fmt.Errorf("binary.Read failed: %v", err)
 	}
 
+	batt, tx := packet.Batt>>5, packet.Batt&0x001F
+
 	return &data.Point{
-		Temperature: float64(packet.Temp) * 0.005,
-		Humidity:    float64(packet.Humid) * 0.0025,
-		Pressure:    (float64(packet.Pres) + 50000.0) / 100.0,
-		Battery:     float64((packet.Batt&0xFFE0)>>5) + 1600.0,
+		Temperature: available(packet.Temp, math.MinInt16, float64(packet.Temp)*0.005),
+		Humidity:    available(packet.Humid, math.MaxUint16, float64(packet.Humid)*0.0025),
+		Pressure:    available(packet.Pres, math.MaxUint16, (float64(packet.Pres)+50000.0)/100.0),
+		Battery:     available(batt, 0x07FF, float64(batt)+1600.0),
+
+		AccelerationX:       available(packet.AccX, math.MinInt16, float64(packet.AccX)/1000.0),
+		AccelerationY:       available(packet.AccY, math.MinInt16, float64(packet.AccY)/1000.0),
+		AccelerationZ:       available(packet.AccZ, math.MinInt16, float64(packet.AccZ)/1000.0),
+		MovementCounter:     available(packet.MvCount, math.MaxUint8, uint32(packet.MvCount)),
+		MeasurementSequence: available(packet.Seq, math.MaxUint16, uint32(packet.Seq)),
+
+		TxPower: available(tx, 0x001F, int(tx)*2-40),
 	}, nil
 }
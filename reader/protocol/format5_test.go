@@ -0,0 +1,87 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func TestParseFormat5(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		raw     string
+		want    *data.Point
+		wantErr bool
+	}{
+		{
+			name: "valid data",
+			raw:  "0512FC5394C37C0004FFFC040CAC364200CDCBB8334C884F",
+			want: &data.Point{
+				Temperature:         data.Ptr(24.3),
+				Humidity:            data.Ptr(53.49),
+				Pressure:            data.Ptr(1000.44),
+				Battery:             data.Ptr[float64](2977),
+				AccelerationX:       data.Ptr(0.004),
+				AccelerationY:       data.Ptr(-0.004),
+				AccelerationZ:       data.Ptr(1.036),
+				MovementCounter:     data.Ptr[uint32](66),
+				MeasurementSequence: data.Ptr[uint32](205),
+				TxPower:             data.Ptr(4),
+			},
+		},
+		{
+			name: "maximum values",
+			raw:  "057FFFFFFEFFFE7FFF7FFF7FFFFFDEFEFFFECBB8334C884F",
+			want: &data.Point{
+				Temperature:         data.Ptr(163.835),
+				Humidity:            data.Ptr(163.835),
+				Pressure:            data.Ptr(1155.34),
+				Battery:             data.Ptr[float64](3646),
+				AccelerationX:       data.Ptr(32.767),
+				AccelerationY:       data.Ptr(32.767),
+				AccelerationZ:       data.Ptr(32.767),
+				MovementCounter:     data.Ptr[uint32](254),
+				MeasurementSequence: data.Ptr[uint32](65534),
+				TxPower:             data.Ptr(20),
+			},
+		},
+		{
+			name: "minimum values",
+			raw:  "058001000000008001800180010000000000CBB8334C884F",
+			want: &data.Point{
+				Temperature:         data.Ptr(-163.835),
+				Humidity:            data.Ptr[float64](0),
+				Pressure:            data.Ptr[float64](500),
+				Battery:             data.Ptr[float64](1600),
+				AccelerationX:       data.Ptr(-32.767),
+				AccelerationY:       data.Ptr(-32.767),
+				AccelerationZ:       data.Ptr(-32.767),
+				MovementCounter:     data.Ptr[uint32](0),
+				MeasurementSequence: data.Ptr[uint32](0),
+				TxPower:             data.Ptr(-40),
+			},
+		},
+		{
+			name: "invalid values",
+			raw:  "058000FFFFFFFF800080008000FFFFFFFFFFFFFFFFFFFFFF",
+			want: &data.Point{},
+		},
+		{
+			name:    "too short",
+			raw:     "0512FC5394C37C0004FFFC040CAC364200CDCBB833",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseFormat5(0x0499, mustHex(t, tc.raw))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseFormat5 err = %v, want error: %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateApprox(0, 0.001)); diff != "" {
+				t.Errorf("parseFormat5 diff -want +got\n%v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func init() {
+	RegisterManufacturerData("format8", 0x0499, 8, func(mfID uint16, raw []byte, addr string, opts *ParseOpts) (*data.Point, error) {
+		return parseFormat8(mfID, raw, opts.Format8Keys[strings.ToUpper(addr)])
+	})
+}
+
+type format8 struct {
+	DataFormat uint8
+	Temp       int16
+	Humid      uint16
+	Pres       uint16
+	Batt       uint16
+	MvCount    uint16
+	Seq        uint16
+	Reserved   [4]uint8
+	CRC        uint8
+	MAC        [6]uint8
+}
+
+// https://github.com/ruuvi/ruuvi-sensor-protocols/blob/master/dataformat_08.md
+func parseFormat8(mfID uint16, raw []byte, key []byte) (*data.Point, error) {
+	if gotMFID, wantMFID := mfID, uint16(0x0499); gotMFID != wantMFID {
+		return nil, fmt.Errorf("mfID mismatch (got %X, want %X)", gotMFID, wantMFID)
+	}
+	if gotLen, wantLen := len(raw), 24; gotLen < wantLen {
+		return nil, fmt.Errorf("packet length mismatch (got %d, want at least %d)", gotLen, wantLen)
+	}
+	if gotFormat, wantFormat := int(raw[0]), 8; gotFormat != wantFormat {
+		return nil, fmt.Errorf("format mismatch (got %d, want %d)", gotFormat, wantFormat)
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("no decryption key")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes.NewCipher failed: %v", err)
+	}
+
+	// Bytes 1-16 are a single AES-128-ECB block.
+	plain := bytes.Clone(raw[0:24])
+	block.Decrypt(plain[1:17], raw[1:17])
+
+	if gotCRC, wantCRC := plain[17], crc8(plain[1:17]); gotCRC != wantCRC {
+		return nil, fmt.Errorf("crc mismatch (got %02X, want %02X); wrong key?", gotCRC, wantCRC)
+	}
+
+	var packet format8
+	if err := binary.Read(bytes.NewReader(plain), binary.BigEndian, &packet); err != nil {
+		return nil, fmt.Errorf("binary.Read failed: %v", err)
+	}
+
+	batt, tx := packet.Batt>>5, packet.Batt&0x001F
+
+	return &data.Point{
+		Temperature: available(packet.Temp, math.MinInt16, float64(packet.Temp)*0.005),
+		Humidity:    available(packet.Humid, math.MaxUint16, float64(packet.Humid)*0.0025),
+		Pressure:    available(packet.Pres, math.MaxUint16, (float64(packet.Pres)+50000.0)/100.0),
+		Battery:     available(batt, 0x07FF, float64(batt)+1600.0),
+
+		MovementCounter:     available(packet.MvCount, math.MaxUint16, uint32(packet.MvCount)),
+		MeasurementSequence: available(packet.Seq, math.MaxUint16, uint32(packet.Seq)),
+
+		TxPower: available(tx, 0x001F, int(tx)*2-40),
+	}, nil
+}
+
+// crc8 implements CRC-8 with polynomial 0x07 and initial value 0x00.
+func crc8(b []byte) uint8 {
+	var crc uint8
+	for _, x := range b {
+		crc ^= x
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,206 @@
+package protocol
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func TestParseFormat8(t *testing.T) {
+	key := mustHex(t, "000102030405060708090A0B0C0D0E0F")
+	otherKey := mustHex(t, "F0F1F2F3F4F5F6F7F8F9FAFBFCFDFEFF")
+
+	// Advertisements carrying the field values of the spec's test vectors. They were encrypted with key by
+	// openssl enc -aes-128-ecb -nopad, and their CRC-8/SMBUS over plaintext bytes 1-16 computed separately,
+	// so that they don't depend on this package's AES and CRC handling.
+	valid := mustHex(t, "087748D5251CA20C15748B1A33A036301049CBB8334C884F")
+	maximum := mustHex(t, "08FCDDC1A8ACEDB0069F6E2705CDB80CE561CBB8334C884F")
+	minimum := mustHex(t, "08456BB7E7AF31F54F280EEA8E63AFD6C1B2CBB8334C884F")
+	notAvailable := mustHex(t, "083E84F75B6CFCB1BF17A426496C9D0D184ACBB8334C884F")
+
+	badCRC := encryptFormat8(t, key, mustHex(t, "0812FC5394C37CAC36004200CD00000000"), mustHex(t, "CBB8334C884F"))
+	badCRC[17] ^= 0xFF
+
+	for _, tc := range []struct {
+		name    string
+		mfID    uint16
+		raw     []byte
+		key     []byte
+		want    *data.Point
+		wantErr bool
+	}{
+		{
+			name: "valid data",
+			mfID: 0x0499,
+			raw:  valid,
+			key:  key,
+			want: &data.Point{
+				Temperature: data.Ptr[float64](24.3),
+				Humidity:    data.Ptr[float64](53.49),
+				Pressure:    data.Ptr[float64](1000.44),
+				Battery:     data.Ptr[float64](2977),
+
+				MovementCounter:     data.Ptr[uint32](66),
+				MeasurementSequence: data.Ptr[uint32](205),
+
+				TxPower: data.Ptr(4),
+			},
+		},
+		{
+			name: "maximum values",
+			mfID: 0x0499,
+			raw:  maximum,
+			key:  key,
+			want: &data.Point{
+				Temperature: data.Ptr[float64](163.835),
+				Humidity:    data.Ptr[float64](163.835),
+				Pressure:    data.Ptr[float64](1155.34),
+				Battery:     data.Ptr[float64](3646),
+
+				MovementCounter:     data.Ptr[uint32](65534),
+				MeasurementSequence: data.Ptr[uint32](65534),
+
+				TxPower: data.Ptr(20),
+			},
+		},
+		{
+			name: "minimum values",
+			mfID: 0x0499,
+			raw:  minimum,
+			key:  key,
+			want: &data.Point{
+				Temperature: data.Ptr[float64](-163.835),
+				Humidity:    data.Ptr[float64](0),
+				Pressure:    data.Ptr[float64](500),
+				Battery:     data.Ptr[float64](1600),
+
+				MovementCounter:     data.Ptr[uint32](0),
+				MeasurementSequence: data.Ptr[uint32](0),
+
+				TxPower: data.Ptr(-40),
+			},
+		},
+		{
+			name: "not available",
+			mfID: 0x0499,
+			raw:  notAvailable,
+			key:  key,
+			want: &data.Point{},
+		},
+		{
+			name:    "no key",
+			mfID:    0x0499,
+			raw:     valid,
+			wantErr: true,
+		},
+		{
+			name:    "wrong key",
+			mfID:    0x0499,
+			raw:     encryptFormat8(t, otherKey, mustHex(t, "0812FC5394C37CAC36004200CD00000000"), mustHex(t, "CBB8334C884F")),
+			key:     key,
+			wantErr: true,
+		},
+		{
+			name:    "bad CRC",
+			mfID:    0x0499,
+			raw:     badCRC,
+			key:     key,
+			wantErr: true,
+		},
+		{
+			name:    "malformed key",
+			mfID:    0x0499,
+			raw:     valid,
+			key:     key[:5],
+			wantErr: true,
+		},
+		{
+			name:    "wrong manufacturer",
+			mfID:    0x0059,
+			raw:     valid,
+			key:     key,
+			wantErr: true,
+		},
+		{
+			name:    "wrong format",
+			mfID:    0x0499,
+			raw:     mustHex(t, "0512FC5394C37C0004FFFC040CAC364200CDCBB8334C884F"),
+			key:     key,
+			wantErr: true,
+		},
+		{
+			name:    "too short",
+			mfID:    0x0499,
+			raw:     valid[:20],
+			key:     key,
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseFormat8(tc.mfID, tc.raw, tc.key)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseFormat8 err = %v, want error: %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateApprox(0, 0.001)); diff != "" {
+				t.Errorf("parseFormat8 diff -want +got\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestParseDatagramFormat8Keys(t *testing.T) {
+	key := mustHex(t, "000102030405060708090A0B0C0D0E0F")
+	raw := mustHex(t, "087748D5251CA20C15748B1A33A036301049CBB8334C884F")
+
+	if _, err := ParseDatagram(0x0499, raw, "cb:b8:33:4c:88:4f", nil); err == nil {
+		t.Errorf("ParseDatagram without keys succeeded, want error")
+	}
+
+	p, err := ParseDatagram(0x0499, raw, "cb:b8:33:4c:88:4f", &ParseOpts{
+		Format8Keys: map[string][]byte{"CB:B8:33:4C:88:4F": key},
+	})
+	if err != nil {
+		t.Fatalf("ParseDatagram failed: %v", err)
+	}
+	if got, want := p.Address, "CB:B8:33:4C:88:4F"; got != want {
+		t.Errorf("p.Address = %q, want %q", got, want)
+	}
+}
+
+func TestCRC8(t *testing.T) {
+	// Check value of CRC-8/SMBUS (polynomial 0x07, initial value 0x00).
+	if got, want := crc8([]byte("123456789")), uint8(0xF4); got != want {
+		t.Errorf("crc8 = %02X, want %02X", got, want)
+	}
+}
+
+// encryptFormat8 builds an advertisement from a plaintext payload; it's only meant for broken inputs.
+func encryptFormat8(t *testing.T, key, plain, mac []byte) []byte {
+	t.Helper()
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("aes.NewCipher failed: %v", err)
+	}
+
+	raw := make([]byte, 24)
+	raw[0] = plain[0]
+	block.Encrypt(raw[1:17], plain[1:17])
+	raw[17] = crc8(plain[1:17])
+	copy(raw[18:24], mac)
+	return raw
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("hex.DecodeString(%q) failed: %v", s, err)
+	}
+	return b
+}
@@ -1,7 +1,6 @@
 package protocol
 
 import (
-	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -9,9 +8,14 @@ import (
 	"github.com/s5i/ruuvi2db/data"
 )
 
+// ParseOpts contains optional inputs for ParseDatagram.
+type ParseOpts struct {
+	// Format8Keys maps upper-case MAC addresses to AES-128 keys used by Data Format 8.
+	Format8Keys map[string][]byte
+}
+
 // ParseDatagram converts raw BLE datagram to data.Point.
-func ParseDatagram(mfID uint16, data []byte, addr string) (dp *data.Point, e error) {
-	errs := []string{}
+func ParseDatagram(mfID uint16, data []byte, addr string, opts *ParseOpts) (dp *data.Point, e error) {
 	defer func() {
 		if dp != nil {
 			dp.Address = strings.ToUpper(addr)
@@ -19,17 +23,48 @@ func ParseDatagram(mfID uint16, data []byte, addr string) (dp *data.Point, e err
 		}
 	}()
 
-	fmt3, err := parseFormat3(mfID, data)
-	if err == nil {
-		return fmt3, nil
+	if opts == nil {
+		opts = &ParseOpts{}
 	}
-	errs = append(errs, fmt.Sprintf("parseFormat3 failed: %v", err))
 
-	fmt5, err := parseFormat5(mfID, data)
-	if err == nil {
-		return fmt5, nil
+	p := registry.manufacturerData(mfID, data)
+	if p == nil {
+		registry.unrecognized.Add(1)
+		if len(data) == 0 {
+			return nil, fmt.Errorf("no parser for mfID %X (empty datagram)", mfID)
+		}
+		return nil, fmt.Errorf("no parser for mfID %X format %d", mfID, data[0])
 	}
-	errs = append(errs, fmt.Sprintf("parseFormat5 failed: %v", err))
 
-	return nil, errors.New(strings.Join(errs, "\n"))
+	return p.run(mfID, data, addr, opts)
+}
+
+// ParseServiceData converts BLE service data to data.Point.
+func ParseServiceData(uuid uint16, data []byte, addr string, opts *ParseOpts) (dp *data.Point, e error) {
+	defer func() {
+		if dp != nil {
+			dp.Address = strings.ToUpper(addr)
+			dp.Timestamp = time.Now()
+		}
+	}()
+
+	if opts == nil {
+		opts = &ParseOpts{}
+	}
+
+	p := registry.serviceData(uuid)
+	if p == nil {
+		registry.unrecognized.Add(1)
+		return nil, fmt.Errorf("no parser for service UUID %X", uuid)
+	}
+
+	return p.run(uuid, data, addr, opts)
+}
+
+// available returns nil if raw equals the "not available" sentinel defined by the format, and &v otherwise.
+func available[R comparable, V any](raw R, sentinel R, v V) *V {
+	if raw == sentinel {
+		return nil
+	}
+	return &v
 }
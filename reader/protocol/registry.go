@@ -0,0 +1,154 @@
+package protocol
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/s5i/ruuvi2db/data"
+)
+
+// Parser converts a raw payload to data.Point.
+// id is the manufacturer ID or the 16-bit service UUID the payload was advertised with.
+// Address and Timestamp of the returned point are filled in by the caller.
+type Parser func(id uint16, raw []byte, addr string, opts *ParseOpts) (*data.Point, error)
+
+// ParserStats contains counters of a single registered parser.
+type ParserStats struct {
+	Name   string
+	Parsed uint64
+	Errors uint64
+}
+
+// Stats contains counters of all registered parsers.
+type Stats struct {
+	Parsers []ParserStats
+
+	// Unrecognized counts payloads no parser was registered for.
+	Unrecognized uint64
+}
+
+// RegisterManufacturerData registers a parser for manufacturer specific data.
+// The parser is selected by the manufacturer ID and the first byte of the payload.
+// It panics if the key or the name is already taken; call it from init().
+func RegisterManufacturerData(name string, mfID uint16, format uint8, p Parser) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	k := mfKey{mfID: mfID, format: format}
+	if _, ok := registry.mf[k]; ok {
+		panic(fmt.Sprintf("protocol: parser for mfID %X format %d registered twice", mfID, format))
+	}
+	registry.mf[k] = registry.add(name, p)
+}
+
+// RegisterServiceData registers a parser for service data with a given 16-bit UUID.
+// It panics if the UUID or the name is already taken; call it from init().
+func RegisterServiceData(name string, uuid uint16, p Parser) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, ok := registry.sd[uuid]; ok {
+		panic(fmt.Sprintf("protocol: parser for service UUID %X registered twice", uuid))
+	}
+	registry.sd[uuid] = registry.add(name, p)
+}
+
+// ManufacturerDataParser returns the name of the parser that would handle the payload, or "" if there's none.
+func ManufacturerDataParser(mfID uint16, raw []byte) string {
+	if e := registry.manufacturerData(mfID, raw); e != nil {
+		return e.name
+	}
+	return ""
+}
+
+// ServiceDataParser returns the name of the parser for service data with the given UUID, or "" if there's none.
+func ServiceDataParser(uuid uint16) string {
+	if e := registry.serviceData(uuid); e != nil {
+		return e.name
+	}
+	return ""
+}
+
+// ReadStats returns a snapshot of parser counters.
+func ReadStats() *Stats {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	ret := &Stats{
+		Unrecognized: registry.unrecognized.Load(),
+	}
+	for _, e := range registry.byName {
+		ret.Parsers = append(ret.Parsers, ParserStats{
+			Name:   e.name,
+			Parsed: e.parsed.Load(),
+			Errors: e.errors.Load(),
+		})
+	}
+	sort.Slice(ret.Parsers, func(i, j int) bool { return ret.Parsers[i].Name < ret.Parsers[j].Name })
+	return ret
+}
+
+type mfKey struct {
+	mfID   uint16
+	format uint8
+}
+
+type entry struct {
+	name   string
+	parse  Parser
+	parsed atomic.Uint64
+	errors atomic.Uint64
+}
+
+func (e *entry) run(id uint16, raw []byte, addr string, opts *ParseOpts) (*data.Point, error) {
+	p, err := e.parse(id, raw, addr, opts)
+	if err != nil {
+		e.errors.Add(1)
+		return nil, fmt.Errorf("%s failed: %v", e.name, err)
+	}
+	e.parsed.Add(1)
+	return p, nil
+}
+
+var registry = &parserRegistry{
+	mf:     map[mfKey]*entry{},
+	sd:     map[uint16]*entry{},
+	byName: map[string]*entry{},
+}
+
+type parserRegistry struct {
+	mu     sync.RWMutex
+	mf     map[mfKey]*entry
+	sd     map[uint16]*entry
+	byName map[string]*entry
+
+	unrecognized atomic.Uint64
+}
+
+// add must be called with mu held.
+func (r *parserRegistry) add(name string, p Parser) *entry {
+	if _, ok := r.byName[name]; ok {
+		panic(fmt.Sprintf("protocol: parser %q registered twice", name))
+	}
+	e := &entry{name: name, parse: p}
+	r.byName[name] = e
+	return e
+}
+
+func (r *parserRegistry) manufacturerData(mfID uint16, raw []byte) *entry {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mf[mfKey{mfID: mfID, format: raw[0]}]
+}
+
+func (r *parserRegistry) serviceData(uuid uint16) *entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sd[uuid]
+}
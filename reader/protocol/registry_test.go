@@ -0,0 +1,100 @@
+package protocol
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func init() {
+	RegisterManufacturerData("test/registry", 0xFFF0, 0x01, func(id uint16, raw []byte, addr string, _ *ParseOpts) (*data.Point, error) {
+		if len(raw) < 2 {
+			return nil, fmt.Errorf("too short")
+		}
+		return &data.Point{Temperature: data.Ptr(float64(raw[1]))}, nil
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	statsOf := func() ParserStats {
+		for _, s := range ReadStats().Parsers {
+			if s.Name == "test/registry" {
+				return s
+			}
+		}
+		t.Fatalf("test/registry missing from ReadStats")
+		return ParserStats{}
+	}
+	before, beforeParser := ReadStats(), statsOf()
+
+	p, err := ParseDatagram(0xFFF0, []byte{0x01, 21}, "aa:aa:aa:aa:aa:aa", nil)
+	if err != nil {
+		t.Fatalf("ParseDatagram failed: %v", err)
+	}
+	if got, want := *p.Temperature, 21.0; got != want {
+		t.Errorf("p.Temperature = %v, want %v", got, want)
+	}
+	if got, want := p.Address, "AA:AA:AA:AA:AA:AA"; got != want {
+		t.Errorf("p.Address = %q, want %q", got, want)
+	}
+
+	if _, err := ParseDatagram(0xFFF0, []byte{0x01}, "aa:aa:aa:aa:aa:aa", nil); err == nil {
+		t.Errorf("ParseDatagram of malformed payload succeeded, want error")
+	}
+	if _, err := ParseDatagram(0xFFF0, []byte{0x02, 21}, "aa:aa:aa:aa:aa:aa", nil); err == nil {
+		t.Errorf("ParseDatagram of unregistered format succeeded, want error")
+	}
+
+	if got, want := statsOf(), (ParserStats{Name: "test/registry", Parsed: beforeParser.Parsed + 1, Errors: beforeParser.Errors + 1}); got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+	if got, want := ReadStats().Unrecognized, before.Unrecognized+1; got != want {
+		t.Errorf("Unrecognized = %d, want %d", got, want)
+	}
+
+	if got, want := ManufacturerDataParser(0xFFF0, []byte{0x01}), "test/registry"; got != want {
+		t.Errorf("ManufacturerDataParser = %q, want %q", got, want)
+	}
+	if got, want := ManufacturerDataParser(0xFFF0, []byte{0x02}), ""; got != want {
+		t.Errorf("ManufacturerDataParser of unregistered format = %q, want %q", got, want)
+	}
+	if got, want := ServiceDataParser(0xFEAA), "format2/format4"; got != want {
+		t.Errorf("ServiceDataParser = %q, want %q", got, want)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		f    func()
+	}{
+		{
+			name: "same key",
+			f: func() {
+				RegisterManufacturerData("test/duplicate", 0x0499, 5, nil)
+			},
+		},
+		{
+			name: "same name",
+			f: func() {
+				RegisterManufacturerData("format5", 0xFFF1, 5, nil)
+			},
+		},
+		{
+			name: "same UUID",
+			f: func() {
+				RegisterServiceData("test/duplicate", 0xFEAA, nil)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registration did not panic")
+				}
+			}()
+			tc.f()
+		})
+	}
+}
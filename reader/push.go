@@ -0,0 +1,161 @@
+package reader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/s5i/ruuvi2db/data"
+)
+
+var (
+	ErrPush = fmt.Errorf("push error")
+
+	// errPushRejected means storage refused the batch; retrying it won't help.
+	errPushRejected = fmt.Errorf("batch rejected")
+)
+
+type RunPusherOpts struct {
+	StorageAddr string
+	PointsCh    <-chan *data.Point
+
+	// Points are sent in batches of up to BatchSize points, at least every FlushPeriod.
+	BatchSize   int
+	FlushPeriod time.Duration
+	// Failed deliveries are retried after a delay that doubles from FlushPeriod up to RetryBackoffMax.
+	RetryBackoffMax time.Duration
+
+	// Undelivered batches are kept in OutboxPath (in memory, if empty), up to OutboxMaxBatches.
+	OutboxPath       string
+	OutboxMaxBatches int
+}
+
+// RunPusher sends new points to the storage ingest endpoint.
+func RunPusher(ctx context.Context, opts *RunPusherOpts) error {
+	endpoint, err := url.JoinPath("http://", opts.StorageAddr, "ingest")
+	if err != nil {
+		return err
+	}
+
+	ob, err := newOutbox(opts.OutboxPath, opts.OutboxMaxBatches)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPush, err)
+	}
+	if n, err := ob.len(); err == nil && n > 0 {
+		log.Printf("push outbox has %d undelivered batches", n)
+	}
+
+	var buf []*data.Point
+	var lastID int64
+	flush := func() {
+		if len(buf) == 0 {
+			return
+		}
+
+		id := max(time.Now().UnixNano(), lastID+1)
+		lastID = id
+
+		dropped, err := ob.add(&data.Batch{
+			ID:     fmt.Sprintf("%020d", id),
+			Points: buf,
+		})
+		buf = nil
+		if err != nil {
+			log.Printf("%v: %v", ErrPush, err)
+		}
+		if dropped > 0 {
+			log.Printf("push outbox full, dropped %d oldest batches", dropped)
+		}
+	}
+
+	backoff := opts.FlushPeriod
+	var retryAt time.Time
+	deliver := func() {
+		for time.Now().After(retryAt) {
+			b, err := ob.oldest()
+			if err != nil {
+				log.Printf("%v: %v", ErrPush, err)
+				return
+			}
+			if b == nil {
+				return
+			}
+
+			switch err := postBatch(ctx, endpoint, b); {
+			case err == nil:
+				backoff = opts.FlushPeriod
+			case errors.Is(err, errPushRejected):
+				log.Printf("%v: dropping batch %s: %v", ErrPush, b.ID, err)
+			default:
+				log.Printf("%v: %v; retrying in %v", ErrPush, err, backoff)
+				retryAt = time.Now().Add(backoff)
+				backoff = min(2*backoff, opts.RetryBackoffMax)
+				return
+			}
+
+			if err := ob.remove(b.ID); err != nil {
+				log.Printf("%v: %v", ErrPush, err)
+				return
+			}
+		}
+	}
+
+	tick := time.NewTicker(opts.FlushPeriod)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			return nil
+		case p := <-opts.PointsCh:
+			buf = append(buf, p)
+			if len(buf) >= opts.BatchSize {
+				flush()
+				deliver()
+			}
+		case <-tick.C:
+			flush()
+			deliver()
+		}
+	}
+}
+
+func postBatch(ctx context.Context, endpoint string, b *data.Batch) error {
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPushRejected, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return fmt.Errorf("%w: %s: %s", errPushRejected, resp.Status, bytes.TrimSpace(msg))
+	default:
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+}
@@ -0,0 +1,121 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func TestRunPusherRetries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var requests int
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// Storage is down for the first few requests.
+		if requests++; requests <= 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		var b data.Batch
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, p := range b.Points {
+			got = append(got, p.Address)
+		}
+		if len(got) == 5 {
+			cancel()
+		}
+	}))
+	defer srv.Close()
+
+	pointsCh := make(chan *data.Point, 5)
+	var want []string
+	for i := 0; i < 5; i++ {
+		addr := fmt.Sprintf("AA:AA:AA:AA:AA:%02X", i)
+		want = append(want, addr)
+		pointsCh <- &data.Point{Address: addr, Timestamp: time.Now()}
+	}
+
+	if err := RunPusher(ctx, &RunPusherOpts{
+		StorageAddr:      strings.TrimPrefix(srv.URL, "http://"),
+		PointsCh:         pointsCh,
+		BatchSize:        2,
+		FlushPeriod:      5 * time.Millisecond,
+		RetryBackoffMax:  20 * time.Millisecond,
+		OutboxPath:       t.TempDir(),
+		OutboxMaxBatches: 10,
+	}); err != nil {
+		t.Fatalf("RunPusher failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("delivered points diff -want +got\n%v", diff)
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		dir  string
+	}{
+		{name: "memory"},
+		{name: "disk", dir: t.TempDir()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ob, err := newOutbox(tc.dir, 2)
+			if err != nil {
+				t.Fatalf("newOutbox failed: %v", err)
+			}
+
+			var dropped int
+			for _, id := range []string{"1", "2", "3"} {
+				n, err := ob.add(&data.Batch{ID: id, Points: []*data.Point{{Address: "AA:AA:AA:AA:AA:AA"}}})
+				if err != nil {
+					t.Fatalf("add failed: %v", err)
+				}
+				dropped += n
+			}
+			if got, want := dropped, 1; got != want {
+				t.Errorf("dropped = %d, want %d", got, want)
+			}
+
+			var gotIDs []string
+			for {
+				b, err := ob.oldest()
+				if err != nil {
+					t.Fatalf("oldest failed: %v", err)
+				}
+				if b == nil {
+					break
+				}
+				gotIDs = append(gotIDs, b.ID)
+				if err := ob.remove(b.ID); err != nil {
+					t.Fatalf("remove failed: %v", err)
+				}
+			}
+			if diff := cmp.Diff([]string{"2", "3"}, gotIDs); diff != "" {
+				t.Errorf("batch IDs diff -want +got\n%v", diff)
+			}
+		})
+	}
+}
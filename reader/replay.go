@@ -0,0 +1,34 @@
+package reader
+
+import (
+	"context"
+	"errors"
+
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+	"github.com/s5i/ruuvi2db/reader/replay"
+)
+
+type RunReplayOpts struct {
+	Path        string
+	Realtime    bool
+	Loop        bool
+	Format8Keys map[string][]byte
+	CachePointF func(*data.Point)
+	TagTracker  *TagTracker
+}
+
+func RunReplay(ctx context.Context, opts *RunReplayOpts) error {
+	switch err := replay.Run(ctx, func(a *bluetooth.Advertisement) {
+		handleAdvertisement(a, opts.Format8Keys, opts.TagTracker, opts.CachePointF)
+	}, &replay.Config{
+		Path:     opts.Path,
+		Realtime: opts.Realtime,
+		Loop:     opts.Loop,
+	}); {
+	case errors.Is(err, context.Canceled):
+		return nil
+	default:
+		return err
+	}
+}
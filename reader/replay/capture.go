@@ -0,0 +1,225 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	ErrFormat = fmt.Errorf("unsupported capture format")
+)
+
+// maxRecordLen bounds allocations on corrupted files; HCI packets are much smaller.
+const maxRecordLen = 1 << 16
+
+// record is a single captured HCI event, without the H4 packet type byte.
+// event is nil for captured packets other than HCI events.
+type record struct {
+	ts    time.Time
+	event []byte
+}
+
+type captureReader interface {
+	next() (*record, error)
+}
+
+// newCaptureReader recognizes btsnoop and pcap files by their magic numbers.
+func newCaptureReader(r io.Reader) (captureReader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(8)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+	}
+
+	switch {
+	case bytes.Equal(magic, []byte("btsnoop\x00")):
+		return newBTSnoopReader(br)
+	case bytes.Equal(magic[0:4], []byte{0xA1, 0xB2, 0xC3, 0xD4}), bytes.Equal(magic[0:4], []byte{0xA1, 0xB2, 0x3C, 0x4D}):
+		return newPcapReader(br, binary.BigEndian)
+	case bytes.Equal(magic[0:4], []byte{0xD4, 0xC3, 0xB2, 0xA1}), bytes.Equal(magic[0:4], []byte{0x4D, 0x3C, 0xB2, 0xA1}):
+		return newPcapReader(br, binary.LittleEndian)
+	default:
+		return nil, fmt.Errorf("%w: unknown magic %X", ErrFormat, magic)
+	}
+}
+
+// https://fte.com/webhelpii/hsu/Content/Technical_Information/BT_Snoop_File_Format.htm
+const (
+	btsnoopH1      = 1001
+	btsnoopH4      = 1002
+	btsnoopMonitor = 2001
+
+	// Microseconds between 0000-01-01 and 1970-01-01.
+	btsnoopEpochDelta = 0x00DCDDB30F2F8000
+)
+
+type btsnoopReader struct {
+	r        io.Reader
+	datalink uint32
+}
+
+func newBTSnoopReader(r io.Reader) (*btsnoopReader, error) {
+	var hdr struct {
+		Magic    [8]byte
+		Version  uint32
+		Datalink uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("%w: btsnoop header: %v", ErrFormat, err)
+	}
+
+	switch hdr.Datalink {
+	case btsnoopH1, btsnoopH4, btsnoopMonitor:
+	default:
+		return nil, fmt.Errorf("%w: btsnoop datalink %d", ErrFormat, hdr.Datalink)
+	}
+
+	return &btsnoopReader{r: r, datalink: hdr.Datalink}, nil
+}
+
+func (br *btsnoopReader) next() (*record, error) {
+	var hdr struct {
+		OrigLen  uint32
+		InclLen  uint32
+		Flags    uint32
+		Drops    uint32
+		TSMicros int64
+	}
+	if err := binary.Read(br.r, binary.BigEndian, &hdr); err != nil {
+		return nil, eofOr(err)
+	}
+	if hdr.InclLen > maxRecordLen {
+		return nil, fmt.Errorf("record too long (%d bytes)", hdr.InclLen)
+	}
+
+	b := make([]byte, hdr.InclLen)
+	if _, err := io.ReadFull(br.r, b); err != nil {
+		return nil, eofOr(err)
+	}
+
+	rec := &record{
+		ts: time.UnixMicro(hdr.TSMicros - btsnoopEpochDelta),
+	}
+
+	switch br.datalink {
+	case btsnoopH1:
+		// Bit 1 of flags marks commands and events; bit 0 marks received packets.
+		if hdr.Flags&0x03 == 0x03 {
+			rec.event = b
+		}
+	case btsnoopH4:
+		if len(b) > 0 && b[0] == h4Event {
+			rec.event = b[1:]
+		}
+	case btsnoopMonitor:
+		// Lower 16 bits of flags carry the monitor opcode.
+		if hdr.Flags&0xFFFF == monitorEvent {
+			rec.event = b
+		}
+	}
+	return rec, nil
+}
+
+const (
+	h4Event      = 0x04
+	monitorEvent = 0x0003
+)
+
+// https://wiki.wireshark.org/Development/LibpcapFileFormat
+const (
+	pcapNanosMagic      = 0xA1B23C4D
+	pcapHeaderLen       = 24
+	pcapRecordHeaderLen = 16
+)
+
+// https://www.tcpdump.org/linktypes.html
+const (
+	linktypeH4         = 187
+	linktypeH4WithPHDR = 201
+	linktypeMonitor    = 254
+)
+
+type pcapReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	linktype uint32
+	nanos    bool
+}
+
+func newPcapReader(r io.Reader, order binary.ByteOrder) (*pcapReader, error) {
+	hdr := make([]byte, pcapHeaderLen)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, fmt.Errorf("%w: pcap header: %v", ErrFormat, err)
+	}
+
+	pr := &pcapReader{
+		r:        r,
+		order:    order,
+		linktype: order.Uint32(hdr[20:24]),
+		nanos:    order.Uint32(hdr[0:4]) == pcapNanosMagic,
+	}
+
+	switch pr.linktype {
+	case linktypeH4, linktypeH4WithPHDR, linktypeMonitor:
+	default:
+		return nil, fmt.Errorf("%w: pcap linktype %d", ErrFormat, pr.linktype)
+	}
+
+	return pr, nil
+}
+
+func (pr *pcapReader) next() (*record, error) {
+	hdr := make([]byte, pcapRecordHeaderLen)
+	if _, err := io.ReadFull(pr.r, hdr); err != nil {
+		return nil, eofOr(err)
+	}
+
+	inclLen := pr.order.Uint32(hdr[8:12])
+	if inclLen > maxRecordLen {
+		return nil, fmt.Errorf("record too long (%d bytes)", inclLen)
+	}
+
+	b := make([]byte, inclLen)
+	if _, err := io.ReadFull(pr.r, b); err != nil {
+		return nil, eofOr(err)
+	}
+
+	sec, frac := int64(pr.order.Uint32(hdr[0:4])), int64(pr.order.Uint32(hdr[4:8]))
+	if !pr.nanos {
+		frac *= int64(time.Microsecond)
+	}
+	rec := &record{
+		ts: time.Unix(sec, frac),
+	}
+
+	switch pr.linktype {
+	case linktypeH4:
+		if len(b) > 0 && b[0] == h4Event {
+			rec.event = b[1:]
+		}
+	case linktypeH4WithPHDR:
+		// 4-byte big-endian direction header precedes the H4 packet.
+		if len(b) > 4 && b[4] == h4Event {
+			rec.event = b[5:]
+		}
+	case linktypeMonitor:
+		// Big-endian adapter index and opcode precede the packet.
+		if len(b) >= 4 && binary.BigEndian.Uint16(b[2:4]) == monitorEvent {
+			rec.event = b[4:]
+		}
+	}
+	return rec, nil
+}
+
+// eofOr maps clean and truncated ends of file to io.EOF.
+func eofOr(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return io.EOF
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package replay
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+)
+
+const (
+	hciEventLEMeta = 0x3E
+
+	leAdvertisingReport         = 0x02
+	leExtendedAdvertisingReport = 0x0D
+
+	extendedReportHeaderLen = 24
+)
+
+// parseHCIEvent extracts advertisements from an HCI event packet (without the H4 packet type byte).
+// Events other than LE advertising reports yield no advertisements.
+func parseHCIEvent(b []byte) ([]*bluetooth.Advertisement, error) {
+	if len(b) < 2 || b[0] != hciEventLEMeta {
+		return nil, nil
+	}
+	if gotLen, wantLen := len(b)-2, int(b[1]); gotLen < wantLen {
+		return nil, fmt.Errorf("event length mismatch (got %d, want %d)", gotLen, wantLen)
+	}
+	b = b[2 : 2+int(b[1])]
+	if len(b) < 2 {
+		return nil, nil
+	}
+
+	switch b[0] {
+	case leAdvertisingReport:
+		return parseAdvertisingReport(b[1:])
+	case leExtendedAdvertisingReport:
+		return parseExtendedAdvertisingReport(b[1:])
+	default:
+		return nil, nil
+	}
+}
+
+// parseAdvertisingReport follows the Core spec layout, where each field is an array of num_reports elements.
+func parseAdvertisingReport(b []byte) ([]*bluetooth.Advertisement, error) {
+	n := int(b[0])
+	b = b[1:]
+
+	// event_type[n], addr_type[n], addr[6n], data_len[n]
+	if gotLen, wantLen := len(b), 9*n; gotLen < wantLen {
+		return nil, fmt.Errorf("advertising report too short (got %d, want at least %d)", gotLen, wantLen)
+	}
+	addrs := b[2*n : 8*n]
+	lens := b[8*n : 9*n]
+	b = b[9*n:]
+
+	dataLen := 0
+	for _, l := range lens {
+		dataLen += int(l)
+	}
+	// data[sum(data_len)], rssi[n]
+	if gotLen, wantLen := len(b), dataLen+n; gotLen < wantLen {
+		return nil, fmt.Errorf("advertising report too short (got %d, want at least %d)", gotLen, wantLen)
+	}
+	rssis := b[dataLen : dataLen+n]
+
+	var ret []*bluetooth.Advertisement
+	for i := 0; i < n; i++ {
+		adv, err := bluetooth.ParseAdvertisingData(b[:lens[i]])
+		if err != nil {
+			return nil, err
+		}
+		b = b[lens[i]:]
+
+		if adv == nil {
+			continue
+		}
+		adv.Addr = addrString(addrs[6*i : 6*i+6])
+		adv.RSSI = int(int8(rssis[i]))
+		ret = append(ret, adv)
+	}
+	return ret, nil
+}
+
+func parseExtendedAdvertisingReport(b []byte) ([]*bluetooth.Advertisement, error) {
+	n := int(b[0])
+	b = b[1:]
+
+	var ret []*bluetooth.Advertisement
+	for i := 0; i < n; i++ {
+		if gotLen, wantLen := len(b), extendedReportHeaderLen; gotLen < wantLen {
+			return nil, fmt.Errorf("extended advertising report too short (got %d, want at least %d)", gotLen, wantLen)
+		}
+		addr, rssi, dataLen := b[3:9], b[13], int(b[23])
+		b = b[extendedReportHeaderLen:]
+
+		if gotLen, wantLen := len(b), dataLen; gotLen < wantLen {
+			return nil, fmt.Errorf("extended advertising report too short (got %d, want at least %d)", gotLen, wantLen)
+		}
+		adv, err := bluetooth.ParseAdvertisingData(b[:dataLen])
+		if err != nil {
+			return nil, err
+		}
+		b = b[dataLen:]
+
+		if adv == nil {
+			continue
+		}
+		adv.Addr = addrString(addr)
+		adv.RSSI = int(int8(rssi))
+		ret = append(ret, adv)
+	}
+	return ret, nil
+}
+
+// addrString formats a little-endian HCI address the way go-ble does.
+func addrString(b []byte) string {
+	mac := make(net.HardwareAddr, 6)
+	for i := range mac {
+		mac[i] = b[5-i]
+	}
+	return mac.String()
+}
@@ -0,0 +1,93 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+)
+
+var (
+	ErrOpen = fmt.Errorf("replay open error")
+	ErrRead = fmt.Errorf("replay read error")
+)
+
+// Config contains options for capture replay.
+type Config struct {
+	// Path points to a btsnoop or pcap file with HCI traffic.
+	Path string
+	// Realtime keeps the original spacing between packets, which are then timestamped on arrival like live ones.
+	// Otherwise packets are replayed as fast as possible, keeping the time they were captured at.
+	Realtime bool
+	// Loop restarts the replay after reaching the end of the file.
+	Loop bool
+}
+
+// Run feeds LE advertising reports found in a capture file to callback, the same way bluetooth.Run does.
+func Run(ctx context.Context, callback func(*bluetooth.Advertisement), cfg *Config) error {
+	for {
+		if err := replayOnce(ctx, callback, cfg); err != nil {
+			return err
+		}
+
+		if !cfg.Loop {
+			return nil
+		}
+	}
+}
+
+func replayOnce(ctx context.Context, callback func(*bluetooth.Advertisement), cfg *Config) error {
+	f, err := os.Open(cfg.Path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrOpen, err)
+	}
+	defer f.Close()
+
+	cr, err := newCaptureReader(f)
+	if err != nil {
+		return fmt.Errorf("%w: %q: %v", ErrOpen, cfg.Path, err)
+	}
+
+	var prevTS time.Time
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rec, err := cr.next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrRead, err)
+		}
+		if rec.event == nil {
+			continue
+		}
+
+		advs, err := parseHCIEvent(rec.event)
+		if err != nil || len(advs) == 0 {
+			continue
+		}
+
+		if cfg.Realtime && !prevTS.IsZero() && rec.ts.After(prevTS) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(rec.ts.Sub(prevTS)):
+			}
+		}
+		prevTS = rec.ts
+
+		for _, adv := range advs {
+			if !cfg.Realtime {
+				adv.Timestamp = rec.ts
+			}
+			callback(adv)
+		}
+	}
+}
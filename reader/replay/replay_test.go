@@ -0,0 +1,146 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+)
+
+var (
+	df5 = mustHex("0512FC5394C37C0004FFFC040CAC364200CDCBB8334C884F")
+
+	// LE Meta / LE Advertising Report with a single report from CB:B8:33:4C:88:4F at -70 dBm.
+	advReport = func() []byte {
+		ad := append([]byte{byte(len(df5) + 3), 0xFF, 0x99, 0x04}, df5...)
+		params := []byte{leAdvertisingReport, 1, 0x03, 0x01, 0x4F, 0x88, 0x4C, 0x33, 0xB8, 0xCB, byte(len(ad))}
+		params = append(params, ad...)
+		params = append(params, byte(0xBA))
+		return append([]byte{hciEventLEMeta, byte(len(params))}, params...)
+	}()
+
+	wantAdv = &bluetooth.Advertisement{
+		Addr:             "cb:b8:33:4c:88:4f",
+		RSSI:             -70,
+		ManufacturerID:   0x0499,
+		ManufacturerData: df5,
+	}
+
+	// Records in test captures are all from 2024-01-01.
+	captureTS = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// capturedAdv returns wantAdv as replayed at full speed, i.e. timestamped with the capture time.
+func capturedAdv() *bluetooth.Advertisement {
+	a := *wantAdv
+	a.Timestamp = captureTS
+	return &a
+}
+
+func TestRunBTSnoop(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("btsnoop\x00")
+	binary.Write(&buf, binary.BigEndian, []uint32{1, btsnoopH4})
+
+	writeRecord := func(flags uint32, pkt []byte) {
+		binary.Write(&buf, binary.BigEndian, []uint32{uint32(len(pkt)), uint32(len(pkt)), flags, 0})
+		binary.Write(&buf, binary.BigEndian, captureTS.UnixMicro()+btsnoopEpochDelta)
+		buf.Write(pkt)
+	}
+	writeRecord(0x02, []byte{0x01, 0x03, 0x0C, 0x00})            // HCI Reset command.
+	writeRecord(0x03, append([]byte{h4Event}, advReport...))     // Advertising report.
+	writeRecord(0x03, append([]byte{h4Event}, 0x0E, 0x01, 0x00)) // Unrelated event.
+
+	if diff := cmp.Diff([]*bluetooth.Advertisement{capturedAdv()}, runFile(t, buf.Bytes(), false)); diff != "" {
+		t.Errorf("Run diff -want +got\n%v", diff)
+	}
+	// Packets replayed in real time are timestamped on arrival.
+	if diff := cmp.Diff([]*bluetooth.Advertisement{wantAdv}, runFile(t, buf.Bytes(), true)); diff != "" {
+		t.Errorf("Run (realtime) diff -want +got\n%v", diff)
+	}
+}
+
+func TestRunPcapMonitor(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, []uint32{0xA1B2C3D4, 0x00040002, 0, 0, 65535, linktypeMonitor})
+
+	writeRecord := func(opcode uint16, pkt []byte) {
+		pkt = append([]byte{0, 0, byte(opcode >> 8), byte(opcode)}, pkt...)
+		binary.Write(&buf, binary.LittleEndian, []uint32{uint32(captureTS.Unix()), 0, uint32(len(pkt)), uint32(len(pkt))})
+		buf.Write(pkt)
+	}
+	writeRecord(0x0002, []byte{0x03, 0x0C, 0x00}) // HCI Reset command.
+	writeRecord(monitorEvent, advReport)
+
+	if diff := cmp.Diff([]*bluetooth.Advertisement{capturedAdv()}, runFile(t, buf.Bytes(), false)); diff != "" {
+		t.Errorf("Run diff -want +got\n%v", diff)
+	}
+}
+
+func TestRunUnknownFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture")
+	if err := os.WriteFile(path, []byte("not a capture file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Run(context.Background(), func(*bluetooth.Advertisement) {}, &Config{Path: path}); err == nil {
+		t.Errorf("Run succeeded, want error")
+	}
+}
+
+func TestParseExtendedAdvertisingReport(t *testing.T) {
+	ad := append([]byte{byte(len(df5) + 3), 0xFF, 0x99, 0x04}, df5...)
+	params := []byte{
+		leExtendedAdvertisingReport, 1,
+		0x00, 0x00, // Event type.
+		0x01,                               // Address type.
+		0x4F, 0x88, 0x4C, 0x33, 0xB8, 0xCB, // Address.
+		0x01, 0x00, 0xFF, // PHYs, SID.
+		0x7F,       // TX power.
+		0xBA,       // RSSI.
+		0x00, 0x00, // Periodic advertising interval.
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Direct address.
+		byte(len(ad)),
+	}
+	params = append(params, ad...)
+
+	got, err := parseHCIEvent(append([]byte{hciEventLEMeta, byte(len(params))}, params...))
+	if err != nil {
+		t.Fatalf("parseHCIEvent failed: %v", err)
+	}
+	if diff := cmp.Diff([]*bluetooth.Advertisement{wantAdv}, got); diff != "" {
+		t.Errorf("parseHCIEvent diff -want +got\n%v", diff)
+	}
+}
+
+func runFile(t *testing.T, b []byte, realtime bool) []*bluetooth.Advertisement {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "capture")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var ret []*bluetooth.Advertisement
+	if err := Run(context.Background(), func(a *bluetooth.Advertisement) {
+		ret = append(ret, a)
+	}, &Config{Path: path, Realtime: realtime}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	return ret
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
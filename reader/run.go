@@ -2,27 +2,182 @@ package reader
 
 import (
 	"context"
+	"log"
 
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/reader/mqtt"
+	"github.com/s5i/ruuvi2db/reader/protocol"
+	"github.com/s5i/ruuvi2db/reader/simulator"
 	"golang.org/x/sync/errgroup"
 )
 
+// pushQueueLen is the number of new points buffered for the pusher.
+const pushQueueLen = 4096
+
 func Run(ctx context.Context, g *errgroup.Group, cfg *Config) {
-	get, put := MakeCache(&MakeCacheOpts{
+	events := &EventHub{}
+	history := NewHistory(&HistoryOpts{
+		MaxAge:    cfg.History.MaxAge,
+		MaxPoints: cfg.History.MaxPoints,
+	})
+	newPointFs := []func(*data.Point){events.Publish, history.Put}
+
+	if cfg.History.Path != "" {
+		if err := history.Load(cfg.History.Path); err != nil {
+			log.Print(err)
+		}
+
+		g.Go(func() error {
+			return RunHistory(ctx, &RunHistoryOpts{
+				History:    history,
+				Path:       cfg.History.Path,
+				SavePeriod: cfg.History.SavePeriod,
+			})
+		})
+	}
+
+	if cfg.ConsumedEndpoints.Storage != "" {
+		pushCh := make(chan *data.Point, pushQueueLen)
+		newPointFs = append(newPointFs, func(p *data.Point) {
+			// Drop rather than stall the sources if the pusher falls behind.
+			select {
+			case pushCh <- p:
+			default:
+			}
+		})
+
+		g.Go(func() error {
+			return RunPusher(ctx, &RunPusherOpts{
+				StorageAddr:      cfg.ConsumedEndpoints.Storage,
+				PointsCh:         pushCh,
+				BatchSize:        cfg.Push.BatchSize,
+				FlushPeriod:      cfg.Push.FlushPeriod,
+				RetryBackoffMax:  cfg.Push.RetryBackoffMax,
+				OutboxPath:       cfg.Push.OutboxPath,
+				OutboxMaxBatches: cfg.Push.OutboxMaxBatches,
+			})
+		})
+	}
+
+	get, put, sequences := MakeCache(&MakeCacheOpts{
 		MaxStaleness: cfg.Data.MaxStaleness,
 		MACFilter:    cfg.Data.MACFilter,
+		StuckTimeout: cfg.Data.StuckTimeout,
+		NewPointF: func(p *data.Point) {
+			for _, f := range newPointFs {
+				f(p)
+			}
+		},
 	})
 
-	g.Go(func() error {
-		return RunBluetooth(ctx, &RunBluetoothOpts{
-			WatchdogTimeout: cfg.Bluetooth.WatchdogTimeout,
-			CachePointF:     put,
-		})
+	adapters := &AdapterStates{}
+	tagTracker := NewTagTracker(&TagTrackerOpts{
+		MACFilter: cfg.Data.MACFilter,
+		MaxAge:    cfg.Data.TagsMaxAge,
 	})
 
+	if !cfg.Bluetooth.Disabled {
+		g.Go(func() error {
+			return RunBluetooth(ctx, &RunBluetoothOpts{
+				DeviceIDs:         cfg.Bluetooth.Devices,
+				WatchdogTimeout:   cfg.Bluetooth.WatchdogTimeout,
+				RestartBackoffMin: cfg.Bluetooth.RestartBackoffMin,
+				RestartBackoffMax: cfg.Bluetooth.RestartBackoffMax,
+				MaxFailures:       cfg.Bluetooth.MaxFailures,
+				Format8Keys:       cfg.Protocol.format8Keys,
+				CachePointF:       put,
+				TagTracker:        tagTracker,
+				States:            adapters,
+			})
+		})
+	}
+
+	if cfg.Replay.Path != "" {
+		g.Go(func() error {
+			return RunReplay(ctx, &RunReplayOpts{
+				Path:        cfg.Replay.Path,
+				Realtime:    cfg.Replay.Realtime,
+				Loop:        cfg.Replay.Loop,
+				Format8Keys: cfg.Protocol.format8Keys,
+				CachePointF: put,
+				TagTracker:  tagTracker,
+			})
+		})
+	}
+
+	if cfg.MQTT.Broker != "" {
+		g.Go(func() error {
+			return RunMQTT(ctx, &RunMQTTOpts{
+				Config: &mqtt.Config{
+					Broker:             cfg.MQTT.Broker,
+					ClientID:           cfg.MQTT.ClientID,
+					Username:           cfg.MQTT.Username,
+					Password:           cfg.MQTT.Password,
+					Topics:             cfg.MQTT.Topics,
+					TLS:                cfg.MQTT.TLS.Enabled,
+					CAFile:             cfg.MQTT.TLS.CAFile,
+					CertFile:           cfg.MQTT.TLS.CertFile,
+					KeyFile:            cfg.MQTT.TLS.KeyFile,
+					InsecureSkipVerify: cfg.MQTT.TLS.InsecureSkipVerify,
+				},
+				Format8Keys: cfg.Protocol.format8Keys,
+				CachePointF: put,
+				TagTracker:  tagTracker,
+			})
+		})
+	}
+
+	if len(cfg.Simulator.Tags) > 0 {
+		var tags []*simulator.Tag
+		for _, t := range cfg.Simulator.Tags {
+			tags = append(tags, &simulator.Tag{
+				Address:              t.Address,
+				Format:               t.Format,
+				Temperature:          t.Temperature,
+				TemperatureAmplitude: t.TemperatureAmplitude,
+				Humidity:             t.Humidity,
+				Pressure:             t.Pressure,
+				Battery:              t.Battery,
+			})
+		}
+
+		g.Go(func() error {
+			return RunSimulator(ctx, &RunSimulatorOpts{
+				Tags:          tags,
+				Interval:      cfg.Simulator.Interval,
+				Noise:         cfg.Simulator.Noise,
+				DropoutRate:   cfg.Simulator.DropoutRate,
+				MalformedRate: cfg.Simulator.MalformedRate,
+				BatteryDrain:  cfg.Simulator.BatteryDrain,
+				Seed:          cfg.Simulator.Seed,
+				CachePointF:   put,
+				TagTracker:    tagTracker,
+			})
+		})
+	}
+
+	if cfg.ProvidedEndpoints.Gateway != "" {
+		g.Go(func() error {
+			return RunGatewayEndpoint(ctx, &RunGatewayEndpointOpts{
+				Listen:      cfg.ProvidedEndpoints.Gateway,
+				Format8Keys: cfg.Protocol.format8Keys,
+				CachePointF: put,
+				TagTracker:  tagTracker,
+			})
+		})
+	}
+
 	g.Go(func() error {
 		return RunDataEndpoint(ctx, &RunDataEndpointOpts{
-			Listen:  cfg.ProvidedEndpoints.Data,
-			PointsF: get,
+			Listen:            cfg.ProvidedEndpoints.Data,
+			PointsF:           get,
+			HistoryF:          history.Since,
+			ParserStatsF:      protocol.ReadStats,
+			SequencesF:        sequences,
+			AdaptersF:         adapters.Read,
+			TagsF:             tagTracker.Read,
+			Events:            events,
+			HeartbeatInterval: cfg.Events.HeartbeatInterval,
 		})
 	})
 }
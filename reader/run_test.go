@@ -0,0 +1,94 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/s5i/ruuvi2db/data"
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v2"
+)
+
+// runReader starts the reader with a simulated tag and returns the address of its data endpoint.
+func runReader(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	cfg := &Config{}
+	if err := yaml.Unmarshal([]byte(`
+bluetooth:
+  disabled: true
+simulator:
+  interval: "10ms"
+  tags:
+    - address: "AA:AA:AA:AA:AA:AA"
+      temperature: 21
+      humidity: 45
+data:
+  max_staleness: "1m"
+`), cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.ProvidedEndpoints.Data = addr
+	if err := cfg.Sanitize(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g, ctx := errgroup.WithContext(ctx)
+	Run(ctx, g, cfg)
+	t.Cleanup(func() {
+		cancel()
+		g.Wait()
+	})
+	return addr
+}
+
+// getJSON polls url until it returns a non-empty JSON array.
+func getJSON[T any](t *testing.T, url string) []T {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resp, err := http.Get(url)
+		if err == nil {
+			var ret []T
+			err = json.NewDecoder(resp.Body).Decode(&ret)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET %s: %s", url, resp.Status)
+			}
+			if err == nil && len(ret) > 0 {
+				return ret
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GET %s: no data (last error: %v)", url, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRunDataEndpoint(t *testing.T) {
+	addr := runReader(t)
+
+	if got := getJSON[*data.Point](t, "http://"+addr+"/data.json"); got[0].Address != "AA:AA:AA:AA:AA:AA" {
+		t.Errorf("/data.json address = %q, want AA:AA:AA:AA:AA:AA", got[0].Address)
+	}
+	if got := getJSON[*data.Point](t, "http://"+addr+"/data.json?since=0"); got[0].Address != "AA:AA:AA:AA:AA:AA" {
+		t.Errorf("/data.json?since=0 address = %q, want AA:AA:AA:AA:AA:AA", got[0].Address)
+	}
+	if got := getJSON[*TagInfo](t, "http://"+addr+"/tags.json"); got[0].Address != "AA:AA:AA:AA:AA:AA" {
+		t.Errorf("/tags.json address = %q, want AA:AA:AA:AA:AA:AA", got[0].Address)
+	}
+}
@@ -0,0 +1,42 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+	"github.com/s5i/ruuvi2db/reader/simulator"
+)
+
+type RunSimulatorOpts struct {
+	Tags          []*simulator.Tag
+	Interval      time.Duration
+	Noise         float64
+	DropoutRate   float64
+	MalformedRate float64
+	BatteryDrain  float64
+	Seed          int64
+	CachePointF   func(*data.Point)
+	TagTracker    *TagTracker
+}
+
+func RunSimulator(ctx context.Context, opts *RunSimulatorOpts) error {
+	switch err := simulator.Run(ctx, func(a *bluetooth.Advertisement) {
+		handleAdvertisement(a, nil, opts.TagTracker, opts.CachePointF)
+	}, &simulator.Config{
+		Tags:          opts.Tags,
+		Interval:      opts.Interval,
+		Noise:         opts.Noise,
+		DropoutRate:   opts.DropoutRate,
+		MalformedRate: opts.MalformedRate,
+		BatteryDrain:  opts.BatteryDrain,
+		Seed:          opts.Seed,
+	}); {
+	case errors.Is(err, context.Canceled):
+		return nil
+	default:
+		return err
+	}
+}
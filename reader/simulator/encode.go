@@ -0,0 +1,71 @@
+package simulator
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+)
+
+// measurement holds the values a simulated tag broadcasts.
+type measurement struct {
+	temperature float64
+	humidity    float64
+	pressure    float64
+	battery     float64
+	accX        float64
+	accY        float64
+	accZ        float64
+	txPower     int
+	mvCount     uint8
+	seq         uint16
+}
+
+// https://github.com/ruuvi/docs/blob/master/communication/bluetooth-advertisements/data-format-3-rawv1.md
+func encodeFormat3(m *measurement) []byte {
+	b := make([]byte, 14)
+	b[0] = 3
+	b[1] = uint8(clamp(math.Round(m.humidity*2), 0, math.MaxUint8))
+
+	t := clamp(math.Abs(m.temperature), 0, 127.99)
+	b[2] = uint8(t)
+	b[3] = uint8(math.Round((t - math.Floor(t)) * 100))
+	if b[3] > 99 {
+		b[3] = 99
+	}
+	if m.temperature < 0 {
+		b[2] |= 1 << 7
+	}
+
+	binary.BigEndian.PutUint16(b[4:6], uint16(clamp(math.Round(m.pressure*100-50000), 0, math.MaxUint16)))
+	binary.BigEndian.PutUint16(b[6:8], uint16(int16(clamp(math.Round(m.accX*1000), math.MinInt16, math.MaxInt16))))
+	binary.BigEndian.PutUint16(b[8:10], uint16(int16(clamp(math.Round(m.accY*1000), math.MinInt16, math.MaxInt16))))
+	binary.BigEndian.PutUint16(b[10:12], uint16(int16(clamp(math.Round(m.accZ*1000), math.MinInt16, math.MaxInt16))))
+	binary.BigEndian.PutUint16(b[12:14], uint16(clamp(math.Round(m.battery), 0, math.MaxUint16)))
+	return b
+}
+
+// https://github.com/ruuvi/docs/blob/master/communication/bluetooth-advertisements/data-format-5-rawv2.md
+// Values are clamped to the valid range, so that "not available" sentinels are never produced.
+func encodeFormat5(m *measurement, mac net.HardwareAddr) []byte {
+	b := make([]byte, 24)
+	b[0] = 5
+	binary.BigEndian.PutUint16(b[1:3], uint16(int16(clamp(math.Round(m.temperature/0.005), math.MinInt16+1, math.MaxInt16))))
+	binary.BigEndian.PutUint16(b[3:5], uint16(clamp(math.Round(m.humidity/0.0025), 0, 40000)))
+	binary.BigEndian.PutUint16(b[5:7], uint16(clamp(math.Round(m.pressure*100-50000), 0, math.MaxUint16-1)))
+	binary.BigEndian.PutUint16(b[7:9], uint16(int16(clamp(math.Round(m.accX*1000), math.MinInt16+1, math.MaxInt16))))
+	binary.BigEndian.PutUint16(b[9:11], uint16(int16(clamp(math.Round(m.accY*1000), math.MinInt16+1, math.MaxInt16))))
+	binary.BigEndian.PutUint16(b[11:13], uint16(int16(clamp(math.Round(m.accZ*1000), math.MinInt16+1, math.MaxInt16))))
+
+	batt := uint16(clamp(math.Round(m.battery-1600), 0, 0x07FE))
+	tx := uint16(clamp(math.Round(float64(m.txPower+40)/2), 0, 0x1E))
+	binary.BigEndian.PutUint16(b[13:15], batt<<5|tx)
+
+	b[15] = min(m.mvCount, math.MaxUint8-1)
+	binary.BigEndian.PutUint16(b[16:18], min(m.seq, math.MaxUint16-1))
+	copy(b[18:24], mac)
+	return b
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
@@ -0,0 +1,179 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+)
+
+var (
+	ErrConfig = fmt.Errorf("simulator config error")
+)
+
+// Tag describes a single simulated RuuviTag.
+type Tag struct {
+	Address string
+	// Format is the data format to broadcast: 3 or 5 (default).
+	Format int
+
+	// Temperature (°C) follows a daily sine curve peaking at 15:00.
+	Temperature          float64
+	TemperatureAmplitude float64
+	// Humidity (%) moves against the temperature curve.
+	Humidity float64
+	// Pressure (hPa) drifts slowly around the given value; defaults to 1013.25.
+	Pressure float64
+	// Battery (mV) at startup; defaults to 3000.
+	Battery float64
+}
+
+// Config contains options for the simulator.
+type Config struct {
+	Tags []*Tag
+
+	// Interval between broadcasts of each tag.
+	Interval time.Duration
+	// Noise scales the random noise added to every quantity; 1 is realistic.
+	Noise float64
+	// DropoutRate and MalformedRate are probabilities that a broadcast is skipped or corrupted.
+	DropoutRate   float64
+	MalformedRate float64
+	// BatteryDrain is the battery voltage drop per day, in mV.
+	BatteryDrain float64
+	// Seed makes runs reproducible; 0 picks a random seed.
+	Seed int64
+}
+
+// Run feeds advertisements of simulated tags to callback, the same way bluetooth.Run does.
+func Run(ctx context.Context, callback func(*bluetooth.Advertisement), cfg *Config) error {
+	if cfg.Interval <= 0 {
+		return fmt.Errorf("%w: interval must be positive", ErrConfig)
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rnd := rand.New(rand.NewSource(seed))
+
+	var tags []*tagState
+	for _, t := range cfg.Tags {
+		mac, err := net.ParseMAC(t.Address)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrConfig, err)
+		}
+		t := *t
+		if t.Format == 0 {
+			t.Format = 5
+		}
+		if t.Format != 3 && t.Format != 5 {
+			return fmt.Errorf("%w: unsupported format %d for %s", ErrConfig, t.Format, t.Address)
+		}
+		if t.Pressure == 0 {
+			t.Pressure = 1013.25
+		}
+		if t.Battery == 0 {
+			t.Battery = 3000
+		}
+
+		tags = append(tags, &tagState{
+			tag:      &t,
+			mac:      mac,
+			pressure: t.Pressure,
+			seq:      uint16(rnd.Intn(math.MaxUint16)),
+		})
+	}
+
+	start := time.Now()
+	tick := time.NewTicker(cfg.Interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-tick.C:
+			for _, t := range tags {
+				adv := t.advance(now, now.Sub(start), cfg, rnd)
+				if adv == nil {
+					continue
+				}
+				callback(adv)
+			}
+		}
+	}
+}
+
+type tagState struct {
+	tag      *Tag
+	mac      net.HardwareAddr
+	pressure float64
+	mvCount  uint8
+	seq      uint16
+}
+
+// advance computes the next broadcast of a tag, or returns nil if it's dropped.
+func (t *tagState) advance(now time.Time, elapsed time.Duration, cfg *Config, rnd *rand.Rand) *bluetooth.Advertisement {
+	t.seq++
+	if t.seq == math.MaxUint16 {
+		t.seq = 0
+	}
+	t.pressure += rnd.NormFloat64() * 0.02 * cfg.Noise
+	t.pressure += (t.tag.Pressure - t.pressure) * 0.001
+	if rnd.Float64() < 0.01 {
+		t.mvCount++
+	}
+
+	if rnd.Float64() < cfg.DropoutRate {
+		return nil
+	}
+
+	hour := float64(now.Hour()) + float64(now.Minute())/60
+	diurnal := math.Sin(2 * math.Pi * (hour - 9) / 24)
+
+	m := &measurement{
+		temperature: t.tag.Temperature + t.tag.TemperatureAmplitude*diurnal + rnd.NormFloat64()*0.05*cfg.Noise,
+		humidity:    clamp(t.tag.Humidity-2*t.tag.TemperatureAmplitude*diurnal+rnd.NormFloat64()*0.2*cfg.Noise, 0, 100),
+		pressure:    t.pressure,
+		battery:     t.tag.Battery - cfg.BatteryDrain*elapsed.Hours()/24 + rnd.NormFloat64()*5*cfg.Noise,
+		accX:        rnd.NormFloat64() * 0.01 * cfg.Noise,
+		accY:        rnd.NormFloat64() * 0.01 * cfg.Noise,
+		accZ:        1 + rnd.NormFloat64()*0.01*cfg.Noise,
+		txPower:     4,
+		mvCount:     t.mvCount,
+		seq:         t.seq,
+	}
+
+	var raw []byte
+	switch t.tag.Format {
+	case 3:
+		raw = encodeFormat3(m)
+	case 5:
+		raw = encodeFormat5(m, t.mac)
+	}
+
+	if rnd.Float64() < cfg.MalformedRate {
+		raw = malform(raw, rnd)
+	}
+
+	return &bluetooth.Advertisement{
+		Addr:             t.mac.String(),
+		RSSI:             -60 + int(rnd.NormFloat64()*5*cfg.Noise),
+		ManufacturerID:   0x0499,
+		ManufacturerData: raw,
+	}
+}
+
+// malform truncates the packet or replaces its format byte, so that parsing is guaranteed to fail.
+func malform(raw []byte, rnd *rand.Rand) []byte {
+	if rnd.Intn(2) == 0 {
+		return raw[:rnd.Intn(len(raw))]
+	}
+	raw[0] = 0xFF
+	return raw
+}
@@ -0,0 +1,119 @@
+package simulator
+
+import (
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/reader/protocol"
+)
+
+func TestEncodeParse(t *testing.T) {
+	mac, _ := net.ParseMAC("CB:B8:33:4C:88:4F")
+	m := &measurement{
+		temperature: -12.34,
+		humidity:    53.5,
+		pressure:    1000.44,
+		battery:     2977,
+		accX:        0.004,
+		accY:        -0.004,
+		accZ:        1.036,
+		txPower:     4,
+		mvCount:     66,
+		seq:         205,
+	}
+
+	for _, tc := range []struct {
+		name string
+		raw  []byte
+		want *data.Point
+	}{
+		{
+			name: "format 3",
+			raw:  encodeFormat3(m),
+			want: &data.Point{
+				Address:       "CB:B8:33:4C:88:4F",
+				Temperature:   data.Ptr(-12.34),
+				Humidity:      data.Ptr(53.5),
+				Pressure:      data.Ptr(1000.44),
+				Battery:       data.Ptr[float64](2977),
+				AccelerationX: data.Ptr(0.004),
+				AccelerationY: data.Ptr(-0.004),
+				AccelerationZ: data.Ptr(1.036),
+			},
+		},
+		{
+			name: "format 5",
+			raw:  encodeFormat5(m, mac),
+			want: &data.Point{
+				Address:             "CB:B8:33:4C:88:4F",
+				Temperature:         data.Ptr(-12.34),
+				Humidity:            data.Ptr(53.5),
+				Pressure:            data.Ptr(1000.44),
+				Battery:             data.Ptr[float64](2977),
+				AccelerationX:       data.Ptr(0.004),
+				AccelerationY:       data.Ptr(-0.004),
+				AccelerationZ:       data.Ptr(1.036),
+				MovementCounter:     data.Ptr[uint32](66),
+				MeasurementSequence: data.Ptr[uint32](205),
+				TxPower:             data.Ptr(4),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := protocol.ParseDatagram(0x0499, tc.raw, mac.String(), nil)
+			if err != nil {
+				t.Fatalf("ParseDatagram failed: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateApprox(0, 0.01), cmpopts.IgnoreFields(data.Point{}, "Timestamp")); diff != "" {
+				t.Errorf("ParseDatagram diff -want +got\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	mac, _ := net.ParseMAC("CB:B8:33:4C:88:4F")
+	newTag := func() *tagState {
+		return &tagState{
+			tag:      &Tag{Address: mac.String(), Format: 5, Temperature: 21, TemperatureAmplitude: 3, Humidity: 45, Pressure: 1013, Battery: 3000},
+			mac:      mac,
+			pressure: 1013,
+		}
+	}
+	rnd := rand.New(rand.NewSource(1))
+	now := time.Date(2024, 6, 1, 15, 0, 0, 0, time.Local)
+
+	t.Run("diurnal peak and battery drain", func(t *testing.T) {
+		adv := newTag().advance(now, 10*24*time.Hour, &Config{BatteryDrain: 10}, rnd)
+		p, err := protocol.ParseDatagram(adv.ManufacturerID, adv.ManufacturerData, adv.Addr, nil)
+		if err != nil {
+			t.Fatalf("ParseDatagram failed: %v", err)
+		}
+		if got, want := *p.Temperature, 24.0; got != want {
+			t.Errorf("Temperature = %v, want %v", got, want)
+		}
+		if got, want := *p.Battery, 2900.0; got != want {
+			t.Errorf("Battery = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("dropout", func(t *testing.T) {
+		if adv := newTag().advance(now, 0, &Config{DropoutRate: 1}, rnd); adv != nil {
+			t.Errorf("advance = %+v, want nil", adv)
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			adv := newTag().advance(now, 0, &Config{MalformedRate: 1}, rnd)
+			if _, err := protocol.ParseDatagram(adv.ManufacturerID, adv.ManufacturerData, adv.Addr, nil); err == nil {
+				t.Errorf("ParseDatagram(%X) succeeded, want error", adv.ManufacturerData)
+			}
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package reader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+	"github.com/s5i/ruuvi2db/reader/protocol"
+)
+
+// ruuviManufacturerID is the Bluetooth SIG company identifier of Ruuvi Innovations.
+const ruuviManufacturerID = 0x0499
+
+type TagTrackerOpts struct {
+	MACFilter []string
+	// Tags not heard from for MaxAge are forgotten.
+	MaxAge time.Duration
+}
+
+// TagInfo describes a Ruuvi advertiser seen by the reader.
+type TagInfo struct {
+	Address string
+	// Format is the name of the parser handling the tag's advertisements.
+	Format string
+
+	FirstSeen time.Time
+	LastSeen  time.Time
+	RSSI      int
+	Packets   uint64
+	// ParseErrors counts advertisements that couldn't be parsed, e.g. Data Format 8 without a key.
+	ParseErrors uint64
+
+	// PassesFilter is false if the tag is excluded by the MAC filter.
+	PassesFilter bool
+	// Sample is the most recent reading, if any could be parsed.
+	Sample *data.Point `json:",omitempty"`
+}
+
+// TagTracker keeps track of all Ruuvi advertisers, including the ones the cache filters out.
+type TagTracker struct {
+	opts   *TagTrackerOpts
+	filter map[string]bool
+
+	mu   sync.Mutex
+	tags map[string]*TagInfo
+}
+
+func NewTagTracker(opts *TagTrackerOpts) *TagTracker {
+	filter := map[string]bool{}
+	for _, m := range opts.MACFilter {
+		filter[strings.ToUpper(m)] = true
+	}
+
+	return &TagTracker{
+		opts:   opts,
+		filter: filter,
+		tags:   map[string]*TagInfo{},
+	}
+}
+
+// Observe records an advertisement along with the result of parsing it.
+// Advertisements that neither parse nor carry Ruuvi manufacturer data are ignored.
+func (t *TagTracker) Observe(a *bluetooth.Advertisement, p *data.Point, err error) {
+	isRuuvi := p != nil || (a.ManufacturerID == ruuviManufacturerID && len(a.ManufacturerData) > 0)
+	if !isRuuvi {
+		return
+	}
+
+	format := advertisementFormat(a)
+	addr := strings.ToUpper(a.Addr)
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	info, ok := t.tags[addr]
+	if !ok {
+		info = &TagInfo{
+			Address:      addr,
+			FirstSeen:    now,
+			PassesFilter: len(t.filter) == 0 || t.filter[addr],
+		}
+		t.tags[addr] = info
+	}
+
+	info.Format = format
+	info.LastSeen = now
+	info.RSSI = a.RSSI
+	info.Packets++
+	if err != nil {
+		info.ParseErrors++
+	}
+	if p != nil {
+		info.Sample = p
+	}
+}
+
+// Read returns a snapshot of known tags, sorted by address.
+func (t *TagTracker) Read() []*TagInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var ret []*TagInfo
+	for addr, info := range t.tags {
+		if t.opts.MaxAge > 0 && info.LastSeen.Add(t.opts.MaxAge).Before(time.Now()) {
+			delete(t.tags, addr)
+			continue
+		}
+		i := *info
+		ret = append(ret, &i)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Address < ret[j].Address })
+	return ret
+}
+
+// advertisementFormat names the parser for the advertisement's payload.
+func advertisementFormat(a *bluetooth.Advertisement) string {
+	if len(a.ManufacturerData) > 0 {
+		if f := protocol.ManufacturerDataParser(a.ManufacturerID, a.ManufacturerData); f != "" {
+			return f
+		}
+	}
+	for uuid := range a.ServiceData {
+		if f := protocol.ServiceDataParser(uuid); f != "" {
+			return f
+		}
+	}
+	if a.ManufacturerID == ruuviManufacturerID && len(a.ManufacturerData) > 0 {
+		return fmt.Sprintf("unknown (%d)", a.ManufacturerData[0])
+	}
+	return "unknown"
+}
@@ -0,0 +1,50 @@
+package reader
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/reader/bluetooth"
+)
+
+func TestTagTracker(t *testing.T) {
+	df5, _ := hex.DecodeString("0512FC5394C37C0004FFFC040CAC364200CDCBB8334C884F")
+	df8 := make([]byte, 24)
+	df8[0] = 8
+
+	filter := []string{"cb:b8:33:4c:88:4f"}
+	tracker := NewTagTracker(&TagTrackerOpts{MACFilter: filter, MaxAge: time.Hour})
+	get, put, _ := MakeCache(&MakeCacheOpts{MaxStaleness: time.Hour, MACFilter: filter})
+
+	for _, a := range []*bluetooth.Advertisement{
+		{Addr: "cb:b8:33:4c:88:4f", RSSI: -70, ManufacturerID: 0x0499, ManufacturerData: df5},
+		{Addr: "cb:b8:33:4c:88:4f", RSSI: -60, ManufacturerID: 0x0499, ManufacturerData: df5},
+		{Addr: "aa:aa:aa:aa:aa:aa", RSSI: -80, ManufacturerID: 0x0499, ManufacturerData: df5},
+		{Addr: "bb:bb:bb:bb:bb:bb", RSSI: -90, ManufacturerID: 0x0499, ManufacturerData: df8}, // No key.
+		{Addr: "cc:cc:cc:cc:cc:cc", RSSI: -50, ManufacturerID: 0x0499, ManufacturerData: []byte{0x42}},
+		{Addr: "dd:dd:dd:dd:dd:dd", RSSI: -50, ManufacturerID: 0x004C, ManufacturerData: []byte{0x02, 0x15}}, // Not a Ruuvi.
+	} {
+		handleAdvertisement(a, nil, tracker, put)
+	}
+
+	want := []*TagInfo{
+		{Address: "AA:AA:AA:AA:AA:AA", Format: "format5", RSSI: -80, Packets: 1, Sample: &data.Point{Address: "AA:AA:AA:AA:AA:AA"}},
+		{Address: "BB:BB:BB:BB:BB:BB", Format: "format8", RSSI: -90, Packets: 1, ParseErrors: 1},
+		{Address: "CB:B8:33:4C:88:4F", Format: "format5", RSSI: -60, Packets: 2, PassesFilter: true, Sample: &data.Point{Address: "CB:B8:33:4C:88:4F"}},
+		{Address: "CC:CC:CC:CC:CC:CC", Format: "unknown (66)", RSSI: -50, Packets: 1, ParseErrors: 1},
+	}
+	if diff := cmp.Diff(want, tracker.Read(),
+		cmpopts.IgnoreFields(TagInfo{}, "FirstSeen", "LastSeen"),
+		cmpopts.IgnoreFields(data.Point{}, "Timestamp", "Temperature", "Humidity", "Pressure", "Battery", "AccelerationX", "AccelerationY", "AccelerationZ", "MovementCounter", "MeasurementSequence", "RSSI", "TxPower"),
+	); diff != "" {
+		t.Errorf("Read diff -want +got\n%v", diff)
+	}
+
+	if got, want := len(get()), 1; got != want {
+		t.Errorf("len(cached points) = %d, want %d", got, want)
+	}
+}
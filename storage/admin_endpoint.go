@@ -3,12 +3,22 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/storage/database"
 )
 
 type RunAdminEndpointOpts struct {
-	Listen    string
-	SetAliasF func(addr, name string) error
+	Listen       string
+	Aliases      database.AliasStore
+	Calibrations database.CalibrationStore
 }
 
 func RunAdminEndpoint(ctx context.Context, opts *RunAdminEndpointOpts) error {
@@ -17,7 +27,13 @@ func RunAdminEndpoint(ctx context.Context, opts *RunAdminEndpointOpts) error {
 
 	mux := http.NewServeMux()
 	mux.Handle("/admin/set_alias", SetAliasHandler(&SetAliasHandlerOpts{
-		SetAliasF: opts.SetAliasF,
+		Aliases: opts.Aliases,
+	}))
+	mux.Handle("/admin/set_calibration", SetCalibrationHandler(&SetCalibrationHandlerOpts{
+		Calibrations: opts.Calibrations,
+	}))
+	mux.Handle("/admin/delete_calibration", DeleteCalibrationHandler(&DeleteCalibrationHandlerOpts{
+		Calibrations: opts.Calibrations,
 	}))
 
 	srv.Handler = mux
@@ -36,7 +52,7 @@ func RunAdminEndpoint(ctx context.Context, opts *RunAdminEndpointOpts) error {
 }
 
 type SetAliasHandlerOpts struct {
-	SetAliasF func(addr, name string) error
+	Aliases database.AliasStore
 }
 
 func SetAliasHandler(opts *SetAliasHandlerOpts) http.HandlerFunc {
@@ -56,9 +72,108 @@ func SetAliasHandler(opts *SetAliasHandlerOpts) http.HandlerFunc {
 			return
 		}
 
-		if err := opts.SetAliasF(addr, name); err != nil {
+		if err := opts.Aliases.SetAlias(r.Context(), addr, name); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+type SetCalibrationHandlerOpts struct {
+	Calibrations database.CalibrationStore
+}
+
+// SetCalibrationHandler stores a calibration: corrected = raw * scale + offset, for points taken at or after "from".
+func SetCalibrationHandler(opts *SetCalibrationHandlerOpts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr, kind, from, err := calibrationParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		offset, err := floatParam(r, "offset", 0)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		scale, err := floatParam(r, "scale", 1)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		if err := opts.Calibrations.SetCalibration(r.Context(), &data.Calibration{
+			Address:       addr,
+			Kind:          kind,
+			EffectiveFrom: from,
+			Offset:        offset,
+			Scale:         scale,
+		}); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+type DeleteCalibrationHandlerOpts struct {
+	Calibrations database.CalibrationStore
+}
+
+func DeleteCalibrationHandler(opts *DeleteCalibrationHandlerOpts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr, kind, from, err := calibrationParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		if err := opts.Calibrations.DeleteCalibration(r.Context(), addr, kind, from); err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
 	}
 }
+
+// calibrationParams returns the parameters identifying a calibration.
+// "from" is in Unix seconds and defaults to the epoch.
+func calibrationParams(r *http.Request) (addr string, kind string, from time.Time, err error) {
+	addr, ok, err := singleStringParam(r, "addr")
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	if !ok {
+		return "", "", time.Time{}, fmt.Errorf("addr not specified")
+	}
+	if _, err := net.ParseMAC(addr); err != nil {
+		return "", "", time.Time{}, fmt.Errorf("malformed addr %q", addr)
+	}
+	addr = strings.ToUpper(addr)
+
+	kind, ok, err = singleStringParam(r, "kind")
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	if !ok {
+		return "", "", time.Time{}, fmt.Errorf("kind not specified")
+	}
+	if !slices.Contains(calibrationKinds, kind) {
+		return "", "", time.Time{}, fmt.Errorf("unrecognized kind %q; valid: %q", kind, calibrationKinds)
+	}
+
+	x, ok, err := singleStringParam(r, "from")
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	from = time.Unix(0, 0)
+	if ok {
+		sec, err := strconv.ParseInt(x, 10, 64)
+		if err != nil {
+			return "", "", time.Time{}, fmt.Errorf("malformed from %q", x)
+		}
+		from = time.Unix(sec, 0)
+	}
+
+	return addr, kind, from, nil
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/s5i/ruuvi2db/data"
+)
+
+// calibrationKinds are the kinds that can be calibrated.
+var calibrationKinds = []string{"temperature", "humidity", "pressure", "battery", "acceleration_x", "acceleration_y", "acceleration_z"}
+
+// calibrate returns copies of points with calibrations applied.
+// A point uses the latest calibration for its address and kind that took effect at or before its timestamp.
+func calibrate(src []*data.Point, cals []*data.Calibration) []*data.Point {
+	if len(cals) == 0 {
+		return src
+	}
+
+	byTag := map[string][]*data.Calibration{}
+	for _, c := range cals {
+		k := strings.ToUpper(c.Address) + "/" + c.Kind
+		byTag[k] = append(byTag[k], c)
+	}
+	for _, cs := range byTag {
+		sort.Slice(cs, func(i, j int) bool { return cs[i].EffectiveFrom.Before(cs[j].EffectiveFrom) })
+	}
+
+	ret := make([]*data.Point, 0, len(src))
+	for _, p := range src {
+		out := *p
+		for _, kind := range calibrationKinds {
+			v := calibrationField(&out, kind)
+			if *v == nil {
+				continue
+			}
+
+			cs := byTag[strings.ToUpper(p.Address)+"/"+kind]
+			i := sort.Search(len(cs), func(i int) bool { return cs[i].EffectiveFrom.After(p.Timestamp) })
+			if i == 0 {
+				continue
+			}
+			*v = data.Ptr(cs[i-1].Apply(**v))
+		}
+		ret = append(ret, &out)
+	}
+	return ret
+}
+
+func calibrationField(p *data.Point, kind string) **float64 {
+	switch kind {
+	case "temperature":
+		return &p.Temperature
+	case "humidity":
+		return &p.Humidity
+	case "pressure":
+		return &p.Pressure
+	case "battery":
+		return &p.Battery
+	case "acceleration_x":
+		return &p.AccelerationX
+	case "acceleration_y":
+		return &p.AccelerationY
+	case "acceleration_z":
+		return &p.AccelerationZ
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func TestCalibrate(t *testing.T) {
+	const addr = "AA:AA:AA:AA:AA:AA"
+	p := func(ts int64, temp, hum float64) *data.Point {
+		return &data.Point{
+			Address:     addr,
+			Timestamp:   time.Unix(ts, 0),
+			Temperature: data.Ptr(temp),
+			Humidity:    data.Ptr(hum),
+		}
+	}
+
+	cals := []*data.Calibration{
+		{Address: addr, Kind: "temperature", EffectiveFrom: time.Unix(200, 0), Offset: -1, Scale: 1},
+		{Address: "aa:aa:aa:aa:aa:aa", Kind: "temperature", EffectiveFrom: time.Unix(100, 0), Offset: -0.5, Scale: 1},
+		{Address: addr, Kind: "humidity", EffectiveFrom: time.Unix(0, 0), Offset: 1, Scale: 2},
+		{Address: "BB:BB:BB:BB:BB:BB", Kind: "temperature", EffectiveFrom: time.Unix(0, 0), Offset: 10, Scale: 1},
+	}
+
+	for _, tc := range []struct {
+		name string
+		in   *data.Point
+		want *data.Point
+	}{
+		{
+			name: "before first temperature calibration",
+			in:   p(50, 20, 40),
+			want: p(50, 20, 81),
+		},
+		{
+			name: "first temperature calibration",
+			in:   p(100, 20, 40),
+			want: p(100, 19.5, 81),
+		},
+		{
+			name: "superseded temperature calibration",
+			in:   p(300, 20, 40),
+			want: p(300, 19, 81),
+		},
+		{
+			name: "missing fields stay missing",
+			in:   &data.Point{Address: addr, Timestamp: time.Unix(300, 0)},
+			want: &data.Point{Address: addr, Timestamp: time.Unix(300, 0)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			before, err := tc.in.Encode()
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+
+			got := calibrate([]*data.Point{tc.in}, cals)
+			if diff := cmp.Diff([]*data.Point{tc.want}, got); diff != "" {
+				t.Errorf("calibrate diff -want +got\n%v", diff)
+			}
+			// Raw values must stay untouched.
+			if after, _ := tc.in.Encode(); !bytes.Equal(before, after) {
+				t.Errorf("calibrate modified its input")
+			}
+		})
+	}
+}
@@ -1,16 +1,16 @@
 package storage
 
 import (
-	"os"
-	"path/filepath"
-	"strings"
 	"time"
+
+	"github.com/s5i/ruuvi2db/storage/database"
 )
 
 type Config struct {
 	ProvidedEndpoints struct {
-		Data  string `yaml:"data"`
-		Admin string `yaml:"admin"`
+		Data   string `yaml:"data"`
+		Admin  string `yaml:"admin"`
+		Ingest string `yaml:"ingest"`
 	} `yaml:"provided_endpoints"`
 
 	ConsumedEndpoints struct {
@@ -21,15 +21,16 @@ type Config struct {
 		QueryPeriod  time.Duration `yaml:"query_period"`
 		MaxStaleness time.Duration `yaml:"max_staleness"`
 		MACFilter    []string      `yaml:"mac_filter"`
+
+		BackfillWindow time.Duration `yaml:"backfill_window"`
 	} `yaml:"reader_consumer"`
 
-	Database struct {
-		Bolt struct {
-			Path              string        `yaml:"path"`
-			RetentionWindow   time.Duration `yaml:"retention_window"`
-			AllowSchemaUpdate bool          `yaml:"allow_schema_update"`
-		} `yaml:"bolt"`
-	} `yaml:"database"`
+	Ingest struct {
+		MACFilter []string `yaml:"mac_filter"`
+	} `yaml:"ingest"`
+
+	// Database selects and configures the backend; see database.Config.
+	Database database.Config `yaml:"database"`
 }
 
 func (cfg *Config) Sanitize() error {
@@ -37,15 +38,9 @@ func (cfg *Config) Sanitize() error {
 		return nil
 	}
 
-	cfg.Database.Bolt.Path = sanitizePath(cfg.Database.Bolt.Path)
-
-	return nil
-}
-
-func sanitizePath(path string) string {
-	if x, ok := strings.CutPrefix(path, "~/"); ok {
-		return filepath.Join(os.Getenv("HOME"), x)
+	if err := cfg.Database.Validate(); err != nil {
+		return err
 	}
 
-	return path
+	return nil
 }
@@ -5,20 +5,24 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"slices"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/storage/database"
 )
 
 type RunDataEndpointOpts struct {
 	Listen       string
-	PointsF      func(startTime, endTime time.Time) ([]*data.Point, error)
-	AliasF       func(string) (string, error)
-	ListAliasesF func() (map[string]string, error)
+	Points       database.PointsReader
+	Aliases      database.AliasStore
+	Calibrations database.CalibrationStore
 }
 
 func RunDataEndpoint(ctx context.Context, opts *RunDataEndpointOpts) error {
@@ -32,12 +36,17 @@ func RunDataEndpoint(ctx context.Context, opts *RunDataEndpointOpts) error {
 	mux := http.NewServeMux()
 
 	mux.Handle("/data.json", DataHandler(&DataHandlerOpts{
-		PointsF: opts.PointsF,
-		AliasF:  opts.AliasF,
+		Points:       opts.Points,
+		Aliases:      opts.Aliases,
+		Calibrations: opts.Calibrations,
 	}))
 
 	mux.Handle("/aliases.json", AliasesHandler(&AliasesHandlerOpts{
-		ListAliasesF: opts.ListAliasesF,
+		Aliases: opts.Aliases,
+	}))
+
+	mux.Handle("/calibrations.json", CalibrationsHandler(&CalibrationsHandlerOpts{
+		Calibrations: opts.Calibrations,
 	}))
 
 	srv.Handler = mux
@@ -56,8 +65,20 @@ func RunDataEndpoint(ctx context.Context, opts *RunDataEndpointOpts) error {
 }
 
 type DataHandlerOpts struct {
-	PointsF func(startTime, endTime time.Time) ([]*data.Point, error)
-	AliasF  func(string) (string, error)
+	Points       database.PointsReader
+	Aliases      database.AliasStore
+	Calibrations database.CalibrationStore
+}
+
+type dataResponse struct {
+	Kinds map[string]*kindResponse `json:"kinds"`
+}
+
+type kindResponse struct {
+	// Unit is omitted for kinds without one, e.g. movement_counter.
+	Unit *Unit `json:"unit,omitempty"`
+	// Points map "ts" and tag addresses to values.
+	Points []map[string]any `json:"points"`
 }
 
 func DataHandler(opts *DataHandlerOpts) http.HandlerFunc {
@@ -74,7 +95,7 @@ func DataHandler(opts *DataHandlerOpts) http.HandlerFunc {
 			return
 		}
 
-		kind, err := dataKind(r)
+		kinds, err := dataKinds(r)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -86,12 +107,38 @@ func DataHandler(opts *DataHandlerOpts) http.HandlerFunc {
 			return
 		}
 
-		src, err := opts.PointsF(endTime.Add(-duration), endTime)
+		units, err := dataUnits(r)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		raw, err := dataRaw(r)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
-		src = align(src, resolution, 2*resolution)
+
+		addrs, err := dataAddrs(r, opts.Aliases)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		src, err := opts.Points.Points(r.Context(), endTime.Add(-duration), endTime, addrs)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if !raw {
+			cals, err := opts.Calibrations.ListCalibrations(r.Context())
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			src = calibrate(src, cals)
+		}
+		pts := align(derive(src, kinds), resolution, 2*resolution)
 
 		w.Header().Set("Content-Type", "application/json")
 
@@ -99,22 +146,37 @@ func DataHandler(opts *DataHandlerOpts) http.HandlerFunc {
 			w.Header().Set("Cache-Control", "public, max-age=604800, immutable")
 		}
 
-		m := map[time.Time]map[string]any{}
-		for _, p := range src {
-			if m[p.Timestamp] == nil {
-				m[p.Timestamp] = map[string]any{}
+		ret := &dataResponse{Kinds: map[string]*kindResponse{}}
+		for _, kind := range kinds {
+			m := map[time.Time]map[string]any{}
+			for _, p := range pts {
+				v := dataValue(p, kind, units)
+				if v == nil {
+					continue
+				}
+
+				if m[p.Timestamp] == nil {
+					m[p.Timestamp] = map[string]any{}
+				}
+
+				m[p.Timestamp]["ts"] = p.Timestamp
+				m[p.Timestamp][p.Address] = v
 			}
 
-			m[p.Timestamp]["ts"] = p.Timestamp
-			m[p.Timestamp][p.Address] = dataValue(p, kind)
-		}
+			k := &kindResponse{
+				Unit:   units.Unit(kind),
+				Points: []map[string]any{},
+			}
+			for _, v := range m {
+				k.Points = append(k.Points, v)
+			}
 
-		ret := []map[string]any{}
-		for _, v := range m {
-			ret = append(ret, v)
-		}
+			sort.Slice(k.Points, func(i, j int) bool {
+				return k.Points[i]["ts"].(time.Time).Before(k.Points[j]["ts"].(time.Time))
+			})
 
-		sort.Slice(ret, func(i, j int) bool { return ret[i]["ts"].(time.Time).Before(ret[j]["ts"].(time.Time)) })
+			ret.Kinds[kind] = k
+		}
 
 		e := json.NewEncoder(w)
 		e.SetIndent("", "  ")
@@ -127,12 +189,12 @@ func DataHandler(opts *DataHandlerOpts) http.HandlerFunc {
 }
 
 type AliasesHandlerOpts struct {
-	ListAliasesF func() (map[string]string, error)
+	Aliases database.AliasStore
 }
 
 func AliasesHandler(opts *AliasesHandlerOpts) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		aliases, err := opts.ListAliasesF()
+		aliases, err := opts.Aliases.ListAliases(r.Context())
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -149,36 +211,162 @@ func AliasesHandler(opts *AliasesHandlerOpts) http.HandlerFunc {
 	}
 }
 
-func dataValue(p *data.Point, kind string) any {
+type CalibrationsHandlerOpts struct {
+	Calibrations database.CalibrationStore
+}
+
+func CalibrationsHandler(opts *CalibrationsHandlerOpts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cals, err := opts.Calibrations.ListCalibrations(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+
+		if err := e.Encode(cals); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+// dataValue returns the value of kind as a number rounded to the unit's precision, or nil if it's missing.
+func dataValue(p *derivedPoint, kind string, units *Units) any {
+	f := func(v *float64) any {
+		if v == nil {
+			return nil
+		}
+		x := units.Convert(kind, *v)
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil
+		}
+		return json.Number(units.Unit(kind).Format(x))
+	}
+	u := func(v *uint32) any {
+		if v == nil {
+			return nil
+		}
+		return json.Number(fmt.Sprintf("%d", *v))
+	}
+	i := func(v *int) any {
+		if v == nil {
+			return nil
+		}
+		return json.Number(fmt.Sprintf("%d", *v))
+	}
+	d := func() any {
+		v, ok := p.derived[kind]
+		if !ok {
+			return nil
+		}
+		return f(&v)
+	}
+
 	switch kind {
 	case "temperature":
-		return fmt.Sprintf("%.2f", p.Temperature)
+		return f(p.Temperature)
 	case "humidity":
-		return fmt.Sprintf("%.2f", p.Humidity)
+		return f(p.Humidity)
 	case "pressure":
-		return fmt.Sprintf("%.2f", p.Pressure)
+		return f(p.Pressure)
 	case "battery":
-		return fmt.Sprintf("%.2f", p.Battery)
+		return f(p.Battery)
+	case "acceleration_x":
+		return f(p.AccelerationX)
+	case "acceleration_y":
+		return f(p.AccelerationY)
+	case "acceleration_z":
+		return f(p.AccelerationZ)
+	case "movement_counter":
+		return u(p.MovementCounter)
+	case "measurement_sequence":
+		return u(p.MeasurementSequence)
+	case "rssi":
+		return i(p.RSSI)
+	case "tx_power":
+		return i(p.TxPower)
+	case "dew_point", "absolute_humidity", "vpd", "heat_index", "air_density":
+		return d()
 	}
 	return nil
 }
 
-var kinds = []string{"temperature", "humidity", "pressure", "battery"}
+var kinds = append([]string{"temperature", "humidity", "pressure", "battery", "acceleration_x", "acceleration_y", "acceleration_z", "movement_counter", "measurement_sequence", "rssi", "tx_power"}, derivedKinds...)
 
-func dataKind(r *http.Request) (string, error) {
-	x, ok, err := singleStringParam(r, "kind")
+// dataKinds returns the kinds requested via repeated or comma-separated "kind" parameters.
+func dataKinds(r *http.Request) ([]string, error) {
+	var ret []string
+	for _, x := range listParam(r, "kind") {
+		if !slices.Contains(kinds, x) {
+			return nil, fmt.Errorf("unrecognized kind %q; valid: %q", x, kinds)
+		}
+		if !slices.Contains(ret, x) {
+			ret = append(ret, x)
+		}
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("kind not specified")
+	}
+
+	return ret, nil
+}
+
+// dataAddrs returns the MAC addresses requested via "addr" and "alias" parameters (repeated or comma-separated).
+// Nil means all addresses.
+func dataAddrs(r *http.Request, aliasStore database.AliasStore) ([]string, error) {
+	var ret []string
+	for _, x := range listParam(r, "addr") {
+		if _, err := net.ParseMAC(x); err != nil {
+			return nil, fmt.Errorf("malformed addr %q", x)
+		}
+		ret = append(ret, strings.ToUpper(x))
+	}
+
+	names := listParam(r, "alias")
+	if len(names) == 0 {
+		return ret, nil
+	}
+
+	aliases, err := aliasStore.ListAliases(r.Context())
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	for _, name := range names {
+		found := false
+		for addr, alias := range aliases {
+			if alias == name {
+				ret = append(ret, strings.ToUpper(addr))
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown alias %q", name)
+		}
+	}
+
+	return ret, nil
+}
+
+// dataRaw reports whether calibrations should be skipped.
+func dataRaw(r *http.Request) (bool, error) {
+	x, ok, err := singleStringParam(r, "raw")
+	if err != nil {
+		return false, err
 	}
 	if !ok {
-		return "", fmt.Errorf("kind not specified")
+		return false, nil
 	}
 
-	if !slices.Contains(kinds, x) {
-		return "", fmt.Errorf("unrecognized kind %q; valid: %q", x, kinds)
+	ret, err := strconv.ParseBool(x)
+	if err != nil {
+		return false, fmt.Errorf("malformed raw %q", x)
 	}
 
-	return x, nil
+	return ret, nil
 }
 
 func dataEndTime(r *http.Request) (time.Time, error) {
@@ -232,28 +420,30 @@ func dataResolution(r *http.Request) (time.Duration, error) {
 	return time.Duration(ret) * time.Second, nil
 }
 
-func align(src []*data.Point, resolution time.Duration, maxGap time.Duration) []*data.Point {
+// align interpolates points of each address at multiples of resolution.
+// Derived kinds are interpolated like measurements.
+func align(src []*derivedPoint, resolution time.Duration, maxGap time.Duration) []*derivedPoint {
 	if resolution == 0 {
 		return src
 	}
-	raw := map[string][]*data.Point{}
+	raw := map[string][]*derivedPoint{}
 	for _, p := range src {
 		raw[p.Address] = append(raw[p.Address], p)
 	}
 	for addr := range raw {
-		slices.SortFunc(raw[addr], func(a, b *data.Point) int {
+		slices.SortFunc(raw[addr], func(a, b *derivedPoint) int {
 			return int(a.Timestamp.Unix() - b.Timestamp.Unix())
 		})
 	}
 	sorted := raw
-	aligned := map[string][]*data.Point{}
+	aligned := map[string][]*derivedPoint{}
 
 	for addr, pts := range sorted {
 		if len(pts) == 0 {
 			continue
 		}
 
-		get := func(i int) (*data.Point, bool) {
+		get := func(i int) (*derivedPoint, bool) {
 			if i < 0 || i >= len(pts) {
 				return nil, false
 			}
@@ -309,21 +499,52 @@ func align(src []*data.Point, resolution time.Duration, maxGap time.Duration) []
 			// Calculate linear extrapolation coefficient.
 			// 0 if outTS == left.Timestamp, 1 if outTS == right.Timestamp
 			// out.Field = left.Field + coeff * (right.Field - left.Field)
+			// Counters and settings can't be interpolated; they're carried over from left.
+			// Fields missing on either side are missing in the output.
 			coeff := float64(outTS.Sub(left.Timestamp)) / float64(right.Timestamp.Sub(left.Timestamp))
 
-			aligned[addr] = append(aligned[addr], &data.Point{
-				Address:     left.Address,
-				Timestamp:   outTS,
-				Temperature: left.Temperature + coeff*(right.Temperature-left.Temperature),
-				Humidity:    left.Humidity + coeff*(right.Humidity-left.Humidity),
-				Pressure:    left.Pressure + coeff*(right.Pressure-left.Pressure),
-				Battery:     left.Battery + coeff*(right.Battery-left.Battery),
-			})
+			lerp := func(l, r *float64) *float64 {
+				if l == nil || r == nil {
+					return nil
+				}
+				return data.Ptr(*l + coeff*(*r-*l))
+			}
+
+			var derived map[string]float64
+			for k, l := range left.derived {
+				if r, ok := right.derived[k]; ok {
+					if derived == nil {
+						derived = map[string]float64{}
+					}
+					derived[k] = l + coeff*(r-l)
+				}
+			}
+
+			var rssi *int
+			if left.RSSI != nil && right.RSSI != nil {
+				rssi = data.Ptr(*left.RSSI + int(math.Round(coeff*float64(*right.RSSI-*left.RSSI))))
+			}
+
+			aligned[addr] = append(aligned[addr], &derivedPoint{Point: &data.Point{
+				Address:             left.Address,
+				Timestamp:           outTS,
+				Temperature:         lerp(left.Temperature, right.Temperature),
+				Humidity:            lerp(left.Humidity, right.Humidity),
+				Pressure:            lerp(left.Pressure, right.Pressure),
+				Battery:             lerp(left.Battery, right.Battery),
+				AccelerationX:       lerp(left.AccelerationX, right.AccelerationX),
+				AccelerationY:       lerp(left.AccelerationY, right.AccelerationY),
+				AccelerationZ:       lerp(left.AccelerationZ, right.AccelerationZ),
+				MovementCounter:     left.MovementCounter,
+				MeasurementSequence: left.MeasurementSequence,
+				RSSI:                rssi,
+				TxPower:             left.TxPower,
+			}, derived: derived})
 			outTS = outTS.Add(resolution)
 		}
 	}
 
-	var ret []*data.Point
+	var ret []*derivedPoint
 	for _, pts := range aligned {
 		ret = append(ret, pts...)
 	}
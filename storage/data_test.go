@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"encoding/json"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -13,7 +15,7 @@ func TestAlign(t *testing.T) {
 	p := func(t, v int) *data.Point {
 		return &data.Point{
 			Timestamp:   time.Unix(int64(t), 0),
-			Temperature: float64(v),
+			Temperature: data.Ptr(float64(v)),
 		}
 	}
 	mkPoints := func(ts ...int) []*data.Point {
@@ -82,9 +84,136 @@ func TestAlign(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			if diff := cmp.Diff(tc.want, align(tc.in, tc.resolution, tc.maxGap), cmpopts.EquateApprox(0, 0.5), cmpopts.EquateEmpty()); diff != "" {
+			if diff := cmp.Diff(tc.want, dataPoints(align(derive(tc.in, nil), tc.resolution, tc.maxGap)), cmpopts.EquateApprox(0, 0.5), cmpopts.EquateEmpty()); diff != "" {
 				t.Errorf("align diff -want +got\n%v", diff)
 			}
 		})
 	}
 }
+
+func TestAlignMissingFields(t *testing.T) {
+	in := []*data.Point{
+		{Timestamp: time.Unix(100, 0), Temperature: data.Ptr(10.0), Humidity: data.Ptr(40.0)},
+		{Timestamp: time.Unix(200, 0), Temperature: data.Ptr(20.0)},
+	}
+	want := []*data.Point{
+		{Timestamp: time.Unix(100, 0), Temperature: data.Ptr(10.0), Humidity: data.Ptr(40.0)},
+		{Timestamp: time.Unix(150, 0), Temperature: data.Ptr(15.0)},
+		{Timestamp: time.Unix(200, 0), Temperature: data.Ptr(20.0)},
+	}
+
+	if diff := cmp.Diff(want, dataPoints(align(derive(in, nil), 50*time.Second, 100*time.Second)), cmpopts.EquateApprox(0, 0.01)); diff != "" {
+		t.Errorf("align diff -want +got\n%v", diff)
+	}
+}
+
+// dataPoints drops derived kinds from points.
+func dataPoints(pts []*derivedPoint) []*data.Point {
+	var ret []*data.Point
+	for _, p := range pts {
+		ret = append(ret, p.Point)
+	}
+	return ret
+}
+
+func TestAlignDerived(t *testing.T) {
+	in := []*derivedPoint{
+		{Point: &data.Point{Timestamp: time.Unix(100, 0), Temperature: data.Ptr(10.0)}, derived: map[string]float64{"dew_point": 1, "vpd": 1}},
+		{Point: &data.Point{Timestamp: time.Unix(200, 0), Temperature: data.Ptr(20.0)}, derived: map[string]float64{"dew_point": 3}},
+	}
+	want := []*derivedPoint{
+		{Point: &data.Point{Timestamp: time.Unix(100, 0), Temperature: data.Ptr(10.0)}, derived: map[string]float64{"dew_point": 1, "vpd": 1}},
+		{Point: &data.Point{Timestamp: time.Unix(150, 0), Temperature: data.Ptr(15.0)}, derived: map[string]float64{"dew_point": 2}},
+		{Point: &data.Point{Timestamp: time.Unix(200, 0), Temperature: data.Ptr(20.0)}, derived: map[string]float64{"dew_point": 3}},
+	}
+
+	if diff := cmp.Diff(want, align(in, 50*time.Second, 100*time.Second), cmp.AllowUnexported(derivedPoint{}), cmpopts.EquateApprox(0, 0.01)); diff != "" {
+		t.Errorf("align diff -want +got\n%v", diff)
+	}
+}
+
+func TestDataHandler(t *testing.T) {
+	const (
+		a = "AA:AA:AA:AA:AA:AA"
+		b = "BB:BB:BB:BB:BB:BB"
+	)
+	ts := time.Unix(1000, 0).UTC()
+	points := []*data.Point{
+		{Address: a, Timestamp: ts, Temperature: data.Ptr(20.0), Humidity: data.Ptr(50.0), RSSI: data.Ptr(-70)},
+		{Address: b, Timestamp: ts, Temperature: data.Ptr(25.0)},
+	}
+
+	db := &fakeDB{
+		points:  points,
+		aliases: map[string]string{b: "Kitchen"},
+	}
+	h := DataHandler(&DataHandlerOpts{
+		Points:       db,
+		Aliases:      db,
+		Calibrations: db,
+	})
+
+	for _, tc := range []struct {
+		name      string
+		query     string
+		wantAddrs []string
+		want      string
+	}{
+		{
+			name:  "several kinds",
+			query: "kind=temperature,rssi&kind=dew_point&units=imperial",
+			want: `{
+  "kinds": {
+    "dew_point": {
+      "unit": {"id": "F", "symbol": "°F"},
+      "points": [{"ts": "1970-01-01T00:16:40Z", "AA:AA:AA:AA:AA:AA": 48.66}]
+    },
+    "rssi": {
+      "unit": {"id": "dBm", "symbol": "dBm"},
+      "points": [{"ts": "1970-01-01T00:16:40Z", "AA:AA:AA:AA:AA:AA": -70}]
+    },
+    "temperature": {
+      "unit": {"id": "F", "symbol": "°F"},
+      "points": [{"ts": "1970-01-01T00:16:40Z", "AA:AA:AA:AA:AA:AA": 68.00, "BB:BB:BB:BB:BB:BB": 77.00}]
+    }
+  }
+}`,
+		},
+		{
+			name:      "addr and alias",
+			query:     "kind=movement_counter&addr=aa:aa:aa:aa:aa:aa&alias=Kitchen",
+			wantAddrs: []string{a, b},
+			want:      `{"kinds": {"movement_counter": {"points": []}}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h(w, httptest.NewRequest("GET", "/data.json?end_time=2000&duration=3600&resolution=0&"+tc.query, nil))
+			if w.Code != 200 {
+				t.Fatalf("status = %d (%s), want 200", w.Code, w.Body)
+			}
+
+			var got, want any
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("json.Unmarshal(%s) failed: %v", w.Body, err)
+			}
+			if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+				t.Fatalf("json.Unmarshal(want) failed: %v", err)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("response diff -want +got\n%v", diff)
+			}
+			if diff := cmp.Diff(tc.wantAddrs, db.queriedAddrs); diff != "" {
+				t.Errorf("Points addrs diff -want +got\n%v", diff)
+			}
+		})
+	}
+
+	for _, query := range []string{"", "kind=nope", "kind=temperature&addr=nope", "kind=temperature&alias=Garage"} {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest("GET", "/data.json?resolution=0&"+query, nil))
+		if w.Code == 200 {
+			t.Errorf("query %q: status = 200, want an error", query)
+		}
+	}
+}
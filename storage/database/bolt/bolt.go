@@ -4,51 +4,88 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/boltdb/bolt"
 	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/storage/database"
+	bolt "go.etcd.io/bbolt"
 )
 
+func init() {
+	database.Register("bolt", func(unmarshal func(any) error) (database.Database, error) {
+		cfg := &Config{}
+		if err := unmarshal(cfg); err != nil {
+			return nil, err
+		}
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("path not specified")
+		}
+		cfg.Path = sanitizePath(cfg.Path)
+		return New(cfg), nil
+	})
+}
+
 // Config contains options for Bolt database.
 type Config struct {
-	Path              string
-	RetentionWindow   time.Duration
-	AllowSchemaUpdate bool
+	Path              string        `yaml:"path"`
+	RetentionWindow   time.Duration `yaml:"retention_window"`
+	AllowSchemaUpdate bool          `yaml:"allow_schema_update"`
 }
 
 // New returns an object that can be used to connect and push to Bolt DB.
-func New() *DB {
+func New(cfg *Config) *DB {
 	return &DB{
-		pushPointsCh:    make(chan pushPointsReq),
-		pointsCh:        make(chan pointsReq),
-		setAliasCh:      make(chan setAliasReq),
-		getAliasCh:      make(chan getAliasReq),
-		listAliasesCh:   make(chan listAliasesReq),
-		retentionTicker: make(chan time.Time),
+		cfg:   cfg,
+		ready: make(chan struct{}),
 	}
 }
 
-// Run starts a connection to DB and handles Push calls.
-func (d *DB) Run(ctx context.Context, cfg *Config) error {
+// DB is safe for concurrent use. Reads run concurrently in their own View transactions;
+// writes, including retention, are serialized by Bolt.
+// Calls made before Run has opened the database wait for it.
+type DB struct {
+	cfg   *Config
+	ready chan struct{}
+	db    *bolt.DB
+}
+
+// initialMmapSize is the address space reserved for the DB file. Growing the file past it remaps it,
+// which waits for read transactions to finish, so a long query could then delay writes.
+// It's address space rather than memory, but kept modest for 32-bit devices.
+const initialMmapSize = 128 << 20
+
+// Run opens the DB and applies retention until ctx is cancelled.
+func (d *DB) Run(ctx context.Context) error {
+	cfg := d.cfg
+
 	db, err := bolt.Open(cfg.Path, 0644, &bolt.Options{
-		Timeout:   time.Second,
-		MmapFlags: syscall.MAP_POPULATE,
+		Timeout:         time.Second,
+		MmapFlags:       syscall.MAP_POPULATE,
+		InitialMmapSize: initialMmapSize,
 	})
 	if err != nil {
 		return err
 	}
+	// Close waits for open transactions to finish.
 	defer db.Close()
 
 	if err := initDB(db, cfg.AllowSchemaUpdate); err != nil {
 		return err
 	}
 
+	d.db = db
+	close(d.ready)
+
+	var retentionTicker <-chan time.Time
 	if cfg.RetentionWindow > 0 {
 		period := cfg.RetentionWindow / 10
 		if period > time.Hour {
@@ -58,27 +95,12 @@ func (d *DB) Run(ctx context.Context, cfg *Config) error {
 		tick := time.NewTicker(period)
 		defer tick.Stop()
 
-		d.retentionTicker = tick.C
+		retentionTicker = tick.C
 	}
 
 	for {
 		select {
-		case req := <-d.pushPointsCh:
-			req.execute(db)
-
-		case req := <-d.pointsCh:
-			req.execute(db)
-
-		case req := <-d.setAliasCh:
-			req.execute(db)
-
-		case req := <-d.getAliasCh:
-			req.execute(db)
-
-		case req := <-d.listAliasesCh:
-			req.execute(db)
-
-		case <-d.retentionTicker:
+		case <-retentionTicker:
 			executeRetention(db, cfg.RetentionWindow)
 
 		case <-ctx.Done():
@@ -87,273 +109,259 @@ func (d *DB) Run(ctx context.Context, cfg *Config) error {
 	}
 }
 
-// PushPoints pushes data points to DB.
-func (d *DB) PushPoints(points []*data.Point) error {
-	respCh := make(chan pushPointsResp, 1)
-	d.pushPointsCh <- pushPointsReq{
-		points: points,
-		respCh: respCh,
+// open returns the Bolt DB once Run has opened it.
+func (d *DB) open(ctx context.Context) (*bolt.DB, error) {
+	select {
+	case <-d.ready:
+		return d.db, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	resp := <-respCh
-	return resp.err
 }
 
-// Points returns data points between (startTime, endTime].
-func (d *DB) Points(startTime, endTime time.Time) ([]*data.Point, error) {
-	respCh := make(chan pointsResp, 1)
-	d.pointsCh <- pointsReq{
-		start:  startTime,
-		end:    endTime,
-		respCh: respCh,
+func (d *DB) view(ctx context.Context, fn func(*bolt.Tx) error) error {
+	db, err := d.open(ctx)
+	if err != nil {
+		return err
 	}
-	resp := <-respCh
-	return resp.points, resp.err
+	return db.View(fn)
 }
 
-// SetAlias sets an alias for a MAC address.
-func (d *DB) SetAlias(addr, name string) error {
-	respCh := make(chan setAliasResp, 1)
-	d.setAliasCh <- setAliasReq{
-		addr:   addr,
-		name:   name,
-		respCh: respCh,
+func (d *DB) update(ctx context.Context, fn func(*bolt.Tx) error) error {
+	db, err := d.open(ctx)
+	if err != nil {
+		return err
 	}
-	resp := <-respCh
-	return resp.err
+	return db.Update(fn)
 }
 
-// Alias returns an alias for a MAC address.
-func (d *DB) Alias(addr string) (string, error) {
-	respCh := make(chan getAliasResp, 1)
-	d.getAliasCh <- getAliasReq{
-		addr:   addr,
-		respCh: respCh,
-	}
-	resp := <-respCh
-	return resp.alias, resp.err
+// PushPoints pushes data points to DB.
+func (d *DB) PushPoints(ctx context.Context, points []*data.Point) error {
+	return d.update(ctx, func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte(pointsRoot))
+		if err != nil {
+			return err
+		}
+
+		for _, dp := range points {
+			dpRaw, err := dp.Encode()
+			if err != nil {
+				return err
+			}
+
+			if err := putPoint(root, dp, dpRaw); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 }
 
-// ListAliases returns an alias for a MAC address.
-func (d *DB) ListAliases() (map[string]string, error) {
-	respCh := make(chan listAliasesResp, 1)
-	d.listAliasesCh <- listAliasesReq{
-		respCh: respCh,
+// putPoint stores an encoded point under window / address / timestamp.
+func putPoint(root *bolt.Bucket, dp *data.Point, dpRaw []byte) error {
+	windowKey, addrKey, tsKey, err := dpKeys(dp)
+	if err != nil {
+		return err
 	}
-	resp := <-respCh
-	return resp.aliases, resp.err
-}
 
-type DB struct {
-	pushPointsCh    chan pushPointsReq
-	pointsCh        chan pointsReq
-	setAliasCh      chan setAliasReq
-	getAliasCh      chan getAliasReq
-	listAliasesCh   chan listAliasesReq
-	retentionTicker <-chan time.Time
-}
+	windowB, err := root.CreateBucketIfNotExists(windowKey)
+	if err != nil {
+		return err
+	}
 
-type pointsReq struct {
-	start time.Time
-	end   time.Time
+	addrB, err := windowB.CreateBucketIfNotExists(addrKey)
+	if err != nil {
+		return err
+	}
 
-	respCh chan pointsResp
+	return addrB.Put(tsKey, dpRaw)
 }
 
-type pointsResp struct {
-	points    []*data.Point
-	pointErrs []string
-	err       error
-}
+// pointsCtxCheckInterval is the number of points scanned between checks for cancellation.
+const pointsCtxCheckInterval = 1024
 
-func (req *pointsReq) execute(db *bolt.DB) {
-	var points []*data.Point
-	var pointErrs []string
+// Points returns data points between (startTime, endTime].
+// If addrs isn't empty, only points of these MAC addresses are returned.
+// The scan stops early if ctx is cancelled.
+func (d *DB) Points(ctx context.Context, startTime, endTime time.Time, addrs []string) ([]*data.Point, error) {
+	var addrKeys [][]byte
+	for _, addr := range addrs {
+		k, err := addrKey(addr)
+		if err != nil {
+			return nil, err
+		}
+		addrKeys = append(addrKeys, k)
+	}
 
-	rStart, rEnd := req.start, req.end
+	// Windows and timestamps are keyed in chronological order: seek to the first one that may
+	// hold points after startTime, and stop at the first one past endTime.
+	startKey, endKey := timestampKey(startTime), timestampKey(endTime)
+	lastWindowKey := windowKey(endTime)
 
-	if err := db.View(func(tx *bolt.Tx) error {
+	var points []*data.Point
+	scanned := 0
+
+	if err := d.view(ctx, func(tx *bolt.Tx) error {
 		root := tx.Bucket([]byte(pointsRoot))
 		if root == nil {
 			return nil
 		}
 
-		return root.ForEach(func(windowKey, _ []byte) error {
+		wc := root.Cursor()
+		for windowKey, _ := wc.Seek(windowKey(startTime.Add(1))); windowKey != nil && bytes.Compare(windowKey, lastWindowKey) <= 0; windowKey, _ = wc.Next() {
 			windowBucket := root.Bucket(windowKey)
 			if windowBucket == nil {
-				return nil
+				continue
 			}
 
-			wStart, wEnd := windowFromKey(windowKey)
-			if !wStart.Before(rEnd) || !rStart.Before(wEnd) {
-				return nil
+			keys := addrKeys
+			if len(keys) == 0 {
+				ac := windowBucket.Cursor()
+				for addrKey, _ := ac.First(); addrKey != nil; addrKey, _ = ac.Next() {
+					keys = append(keys, addrKey)
+				}
 			}
 
-			return windowBucket.ForEach(func(addrKey, _ []byte) error {
+			for _, addrKey := range keys {
 				addrBucket := windowBucket.Bucket(addrKey)
 				if addrBucket == nil {
-					return nil
+					continue
 				}
 
-				return addrBucket.ForEach(func(tsKey, dpRaw []byte) error {
-					dp, err := data.DecodePoint(dpRaw)
-					if err != nil {
-						pointErrs = append(pointErrs, fmt.Sprintf("bad point @ %s / %X (%v) / %X (%v) / %X (%v): %X", pointsRoot, windowKey, wEnd, addrKey, net.HardwareAddr(addrKey), tsKey, tsFromKey(tsKey), dpRaw))
-						return nil
+				c := addrBucket.Cursor()
+				tsKey, dpRaw := c.Seek(startKey)
+				if bytes.Equal(tsKey, startKey) {
+					tsKey, dpRaw = c.Next()
+				}
+				for ; tsKey != nil && bytes.Compare(tsKey, endKey) <= 0; tsKey, dpRaw = c.Next() {
+					if scanned++; scanned%pointsCtxCheckInterval == 0 {
+						if err := ctx.Err(); err != nil {
+							return err
+						}
 					}
 
-					ts := dp.Timestamp
-					if !ts.After(rStart) || ts.After(rEnd) {
-						return nil
+					dp, err := data.DecodePoint(dpRaw)
+					if err != nil {
+						_, wEnd := windowFromKey(windowKey)
+						log.Printf("bad point @ %s / %X (%v) / %X (%v) / %X (%v): %X", pointsRoot, windowKey, wEnd, addrKey, net.HardwareAddr(addrKey), tsKey, tsFromKey(tsKey), dpRaw)
+						continue
 					}
 
 					points = append(points, dp)
-					return nil
-				})
-			})
-		})
+				}
+			}
+		}
+		return ctx.Err()
 	}); err != nil {
-		req.respCh <- pointsResp{err: err}
-	}
-	req.respCh <- pointsResp{
-		points:    points,
-		pointErrs: pointErrs,
+		return nil, err
 	}
-}
 
-type pushPointsReq struct {
-	points []*data.Point
-
-	respCh chan pushPointsResp
-}
-
-type pushPointsResp struct {
-	err error
+	return points, nil
 }
 
-func (req *pushPointsReq) execute(db *bolt.DB) {
-	if err := db.Update(func(tx *bolt.Tx) error {
-		root, err := tx.CreateBucketIfNotExists([]byte(pointsRoot))
+// SetAlias sets an alias for a MAC address.
+func (d *DB) SetAlias(ctx context.Context, addr, name string) error {
+	return d.update(ctx, func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(aliasesRoot))
 		if err != nil {
 			return err
 		}
 
-		for _, dp := range req.points {
-			dpRaw, err := dp.Encode()
-			if err != nil {
-				return err
-			}
-
-			windowKey, addrKey, tsKey, err := dpKeys(dp)
-			if err != nil {
-				return err
-			}
-
-			windowB, err := root.CreateBucketIfNotExists(windowKey)
-			if err != nil {
-				return err
-			}
+		if name == "" {
+			return b.Delete([]byte(addr))
+		}
 
-			addrB, err := windowB.CreateBucketIfNotExists(addrKey)
-			if err != nil {
-				return err
-			}
+		return b.Put([]byte(addr), []byte(name))
+	})
+}
 
-			if err := addrB.Put(tsKey, dpRaw); err != nil {
-				return err
-			}
+// Alias returns an alias for a MAC address.
+func (d *DB) Alias(ctx context.Context, addr string) (string, error) {
+	var alias string
+	if err := d.view(ctx, func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(aliasesRoot))
+		if root == nil {
+			return nil
 		}
 
+		alias = string(root.Get([]byte(addr)))
 		return nil
 	}); err != nil {
-		req.respCh <- pushPointsResp{err: err}
+		return "", err
 	}
-	req.respCh <- pushPointsResp{}
-}
-
-type setAliasReq struct {
-	addr string
-	name string
-
-	respCh chan setAliasResp
-}
-
-type setAliasResp struct {
-	err error
+	return alias, nil
 }
 
-func (req *setAliasReq) execute(db *bolt.DB) {
-	if err := db.Update(func(tx *bolt.Tx) error {
-		b, err := tx.CreateBucketIfNotExists([]byte(aliasesRoot))
-		if err != nil {
-			return err
-		}
-
-		if req.name == "" {
-			return b.Delete([]byte(req.addr))
+// ListAliases returns aliases of all MAC addresses.
+func (d *DB) ListAliases(ctx context.Context) (map[string]string, error) {
+	aliases := map[string]string{}
+	if err := d.view(ctx, func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(aliasesRoot))
+		if root == nil {
+			return nil
 		}
 
-		return b.Put([]byte(req.addr), []byte(req.name))
+		return root.ForEach(func(addr, alias []byte) error {
+			aliases[string(addr)] = string(alias)
+			return nil
+		})
 	}); err != nil {
-		req.respCh <- setAliasResp{err: err}
+		return nil, err
 	}
-
-	req.respCh <- setAliasResp{}
+	return aliases, nil
 }
 
-type getAliasReq struct {
-	addr string
+// SetCalibration adds a calibration, replacing one for the same address, kind and effective-from time.
+func (d *DB) SetCalibration(ctx context.Context, c *data.Calibration) error {
+	v, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
 
-	respCh chan getAliasResp
-}
+	return d.update(ctx, func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(calibrationsRoot))
+		if err != nil {
+			return err
+		}
 
-type getAliasResp struct {
-	alias string
-	err   error
+		return b.Put(calibrationKey(c.Address, c.Kind, c.EffectiveFrom), v)
+	})
 }
 
-func (req *getAliasReq) execute(db *bolt.DB) {
-	var alias string
-	if err := db.View(func(tx *bolt.Tx) error {
-		root := tx.Bucket([]byte(aliasesRoot))
-		if root == nil {
+// DeleteCalibration removes a calibration.
+func (d *DB) DeleteCalibration(ctx context.Context, addr, kind string, effectiveFrom time.Time) error {
+	return d.update(ctx, func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(calibrationsRoot))
+		if b == nil {
 			return nil
 		}
 
-		alias = string(root.Get([]byte(req.addr)))
-		return nil
-	}); err != nil {
-		req.respCh <- getAliasResp{err: err}
-	}
-	req.respCh <- getAliasResp{alias: alias}
-}
-
-type listAliasesReq struct {
-	respCh chan listAliasesResp
-}
-
-type listAliasesResp struct {
-	aliases map[string]string
-	err     error
+		return b.Delete(calibrationKey(addr, kind, effectiveFrom))
+	})
 }
 
-func (req *listAliasesReq) execute(db *bolt.DB) {
-	aliases := map[string]string{}
-	if err := db.View(func(tx *bolt.Tx) error {
-		root := tx.Bucket([]byte(aliasesRoot))
-		if root == nil {
+// ListCalibrations returns all calibrations.
+func (d *DB) ListCalibrations(ctx context.Context) ([]*data.Calibration, error) {
+	calibrations := []*data.Calibration{}
+	if err := d.view(ctx, func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(calibrationsRoot))
+		if b == nil {
 			return nil
 		}
 
-		root.ForEach(func(addr, alias []byte) error {
-			aliases[string(addr)] = string(alias)
+		return b.ForEach(func(_, v []byte) error {
+			c := &data.Calibration{}
+			if err := json.Unmarshal(v, c); err != nil {
+				return err
+			}
+			calibrations = append(calibrations, c)
 			return nil
 		})
-
-		return nil
 	}); err != nil {
-		req.respCh <- listAliasesResp{err: err}
+		return nil, err
 	}
-	req.respCh <- listAliasesResp{aliases: aliases}
+	return calibrations, nil
 }
 
 func executeRetention(db *bolt.DB, retention time.Duration) {
@@ -367,14 +375,14 @@ func executeRetention(db *bolt.DB, retention time.Duration) {
 			return nil
 		}
 
+		// Windows are in chronological order; stop at the first one to keep.
 		var toDelete [][]byte
-		if err := root.ForEach(func(windowKey, _ []byte) error {
-			if _, wEnd := windowFromKey(windowKey); wEnd.Add(retention).Before(time.Now()) {
-				toDelete = append(toDelete, windowKey)
+		c := root.Cursor()
+		for windowKey, _ := c.First(); windowKey != nil; windowKey, _ = c.Next() {
+			if _, wEnd := windowFromKey(windowKey); !wEnd.Add(retention).Before(time.Now()) {
+				break
 			}
-			return nil
-		}); err != nil {
-			return err
+			toDelete = append(toDelete, windowKey)
 		}
 
 		for _, windowKey := range toDelete {
@@ -394,9 +402,10 @@ func windowKey(ts time.Time) []byte {
 	return timestampKey(r)
 }
 
+// timestampKey sorts in chronological order: it's the big-endian nanosecond timestamp with the sign bit flipped.
 func timestampKey(ts time.Time) []byte {
 	b := make([]byte, 8)
-	binary.LittleEndian.PutUint64(b, uint64(int64(^uint64(0)>>1)-ts.UnixNano()))
+	binary.BigEndian.PutUint64(b, uint64(ts.UnixNano())^(1<<63))
 	return b
 }
 
@@ -420,8 +429,15 @@ func dpKeys(dp *data.Point) (window []byte, addr []byte, ts []byte, err error) {
 	return
 }
 
+// calibrationKey sorts calibrations by address, kind and effective-from time.
+func calibrationKey(addr, kind string, effectiveFrom time.Time) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(effectiveFrom.UnixNano()))
+	return append([]byte(addr+"/"+kind+"/"), b...)
+}
+
 func tsFromKey(b []byte) time.Time {
-	return time.Unix(0, -int64(binary.LittleEndian.Uint64(b)-(^uint64(0)>>1)))
+	return time.Unix(0, int64(binary.BigEndian.Uint64(b)^(1<<63)))
 }
 
 func windowFromTs(ts time.Time) (l time.Time, r time.Time) {
@@ -438,11 +454,15 @@ func windowFromKey(b []byte) (l time.Time, r time.Time) {
 
 const (
 	metadataRoot = `metadata`
-	pointsRoot   = `points`
+	pointsRoot   = `points_v3`
 	aliasesRoot  = `aliases`
+	// pointsRootV2 held points keyed in an order that didn't allow range scans.
+	pointsRootV2 = `points`
+	// calibrationsRoot is created on first use; older databases don't need a schema update.
+	calibrationsRoot = `calibrations`
 
 	metadataVersionKey     = `version`
-	metadataVersionCurrent = 2
+	metadataVersionCurrent = 3
 
 	pointsWindowSize = 24 * time.Hour
 )
@@ -499,6 +519,11 @@ func initDB(db *bolt.DB, allowSchemaUpdate bool) error {
 				return err
 			}
 			v = 2
+		case 2:
+			if err := rewriteV2toV3(db); err != nil {
+				return err
+			}
+			v = 3
 		}
 	}
 }
@@ -508,7 +533,7 @@ func rewriteV1toV2(db *bolt.DB) error {
 		if _, err := tx.CreateBucketIfNotExists([]byte(metadataRoot)); err != nil {
 			return err
 		}
-		if _, err := tx.CreateBucketIfNotExists([]byte(pointsRoot)); err != nil {
+		if _, err := tx.CreateBucketIfNotExists([]byte(pointsRootV2)); err != nil {
 			return err
 		}
 		if _, err := tx.CreateBucketIfNotExists([]byte(aliasesRoot)); err != nil {
@@ -518,7 +543,8 @@ func rewriteV1toV2(db *bolt.DB) error {
 			return err
 		}
 
-		root := tx.Bucket([]byte(pointsRoot))
+		// Keys don't matter here; rewriteV2toV3 re-keys points by their values.
+		root := tx.Bucket([]byte(pointsRootV2))
 
 		var toDelete [][]byte
 
@@ -526,7 +552,7 @@ func rewriteV1toV2(db *bolt.DB) error {
 			switch {
 			case bytes.Equal(name, []byte(metadataRoot)):
 				return nil
-			case bytes.Equal(name, []byte(pointsRoot)):
+			case bytes.Equal(name, []byte(pointsRootV2)):
 				return nil
 			case bytes.Equal(name, []byte(aliasesRoot)):
 				return nil
@@ -573,3 +599,76 @@ func rewriteV1toV2(db *bolt.DB) error {
 		return nil
 	})
 }
+
+// rewriteV2toV3 moves points to a bucket keyed in chronological order, re-encoding them in the current encoding.
+// Windows are copied in separate transactions to bound memory use; an interrupted rewrite is simply redone.
+func rewriteV2toV3(db *bolt.DB) error {
+	var windows [][]byte
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(pointsRoot)); err != nil {
+			return err
+		}
+
+		old := tx.Bucket([]byte(pointsRootV2))
+		if old == nil {
+			return nil
+		}
+
+		return old.ForEach(func(windowKey, _ []byte) error {
+			windows = append(windows, bytes.Clone(windowKey))
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+
+	for _, windowKey := range windows {
+		if err := db.Update(func(tx *bolt.Tx) error {
+			root := tx.Bucket([]byte(pointsRoot))
+
+			windowB := tx.Bucket([]byte(pointsRootV2)).Bucket(windowKey)
+			if windowB == nil {
+				return nil
+			}
+
+			return windowB.ForEach(func(addrKey, _ []byte) error {
+				addrB := windowB.Bucket(addrKey)
+				if addrB == nil {
+					return nil
+				}
+
+				return addrB.ForEach(func(_, v []byte) error {
+					dp, err := data.DecodePoint(v)
+					if err != nil {
+						log.Printf("dropping undecodable point during schema update: %v", err)
+						return nil
+					}
+					dpRaw, err := dp.Encode()
+					if err != nil {
+						return err
+					}
+					return putPoint(root, dp, dpRaw)
+				})
+			})
+		}); err != nil {
+			return err
+		}
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(pointsRootV2)) != nil {
+			if err := tx.DeleteBucket([]byte(pointsRootV2)); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket([]byte(metadataRoot)).Put([]byte(metadataVersionKey), []byte(fmt.Sprint(3)))
+	})
+}
+
+func sanitizePath(path string) string {
+	if x, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(os.Getenv("HOME"), x)
+	}
+
+	return path
+}
@@ -0,0 +1,255 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/storage/database"
+	"github.com/s5i/ruuvi2db/storage/database/databasetest"
+	bolt "go.etcd.io/bbolt"
+)
+
+// runDB starts a DB on path, stopping it when the test ends.
+func runDB(tb testing.TB, path string, allowSchemaUpdate bool) *DB {
+	tb.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	db := New(&Config{Path: path, AllowSchemaUpdate: allowSchemaUpdate})
+	go func() {
+		errCh <- db.Run(ctx)
+	}()
+
+	tb.Cleanup(func() {
+		cancel()
+		if err := <-errCh; err != nil {
+			tb.Errorf("Run failed: %v", err)
+		}
+	})
+	return db
+}
+
+func timestamps(points []*data.Point) []int64 {
+	var ret []int64
+	for _, p := range points {
+		ret = append(ret, p.Timestamp.Unix())
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
+func TestConformance(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T) database.Database {
+		return New(&Config{Path: filepath.Join(t.TempDir(), "db")})
+	})
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	db := runDB(t, filepath.Join(t.TempDir(), "db"), false)
+	ctx := context.Background()
+
+	// Keep a read transaction open, as a long query would.
+	inView := make(chan struct{})
+	release := make(chan struct{})
+	viewErr := make(chan error, 1)
+	go func() {
+		viewErr <- db.view(ctx, func(*bolt.Tx) error {
+			close(inView)
+			<-release
+			return nil
+		})
+	}()
+	<-inView
+
+	done := make(chan error, 1)
+	go func() {
+		if err := db.PushPoints(ctx, []*data.Point{{Address: "AA:AA:AA:AA:AA:AA", Timestamp: time.Unix(100, 0)}}); err != nil {
+			done <- err
+			return
+		}
+		if err := db.SetAlias(ctx, "AA:AA:AA:AA:AA:AA", "Kitchen"); err != nil {
+			done <- err
+			return
+		}
+		_, err := db.Points(ctx, time.Unix(0, 0), time.Unix(200, 0), nil)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("writes and reads alongside an open read failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("writes and reads blocked by an open read")
+	}
+
+	close(release)
+	if err := <-viewErr; err != nil {
+		t.Errorf("view failed: %v", err)
+	}
+}
+
+func TestPointsCancelled(t *testing.T) {
+	db := runDB(t, filepath.Join(t.TempDir(), "db"), false)
+
+	var points []*data.Point
+	for i := 0; i < 2*pointsCtxCheckInterval; i++ {
+		points = append(points, &data.Point{Address: "AA:AA:AA:AA:AA:AA", Timestamp: time.Unix(int64(i), 0)})
+	}
+	if err := db.PushPoints(context.Background(), points); err != nil {
+		t.Fatalf("PushPoints failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.Points(ctx, time.Unix(-1, 0), time.Unix(int64(len(points)), 0), nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Points with a cancelled context = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestSchemaUpdateV2(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+
+	// Write a v2 database: V1-encoded points keyed by little-endian reversed timestamps.
+	v1Point := func(ts time.Time) []byte {
+		b := binary.BigEndian.AppendUint64(nil, uint64(ts.UnixNano()))
+		for _, v := range []float64{21.5, 45.25, 1013.25, 2950} {
+			b = binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+		}
+		return append(b, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA)
+	}
+	v2Key := func(ts time.Time) []byte {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, uint64(int64(^uint64(0)>>1)-ts.UnixNano()))
+		return b
+	}
+	want := []int64{100, 200, 100000, 200000}
+	bdb, err := bolt.Open(path, 0644, nil)
+	if err != nil {
+		t.Fatalf("bolt.Open failed: %v", err)
+	}
+	if err := bdb.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucket([]byte(metadataRoot))
+		if err != nil {
+			return err
+		}
+		if err := meta.Put([]byte(metadataVersionKey), []byte("2")); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket([]byte(aliasesRoot)); err != nil {
+			return err
+		}
+
+		root, err := tx.CreateBucket([]byte(pointsRootV2))
+		if err != nil {
+			return err
+		}
+		for _, ts := range want {
+			dp := &data.Point{Address: "AA:AA:AA:AA:AA:AA", Timestamp: time.Unix(ts, 0)}
+			_, r := windowFromTs(dp.Timestamp)
+			windowB, err := root.CreateBucketIfNotExists(v2Key(r))
+			if err != nil {
+				return err
+			}
+			addrB, err := windowB.CreateBucketIfNotExists([]byte{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA})
+			if err != nil {
+				return err
+			}
+			if err := addrB.Put(v2Key(dp.Timestamp), v1Point(dp.Timestamp)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("writing v2 database failed: %v", err)
+	}
+	bdb.Close()
+
+	db := runDB(t, path, true)
+	got, err := db.Points(context.Background(), time.Unix(0, 0), time.Unix(1000000, 0), nil)
+	if err != nil {
+		t.Fatalf("Points failed: %v", err)
+	}
+	if diff := cmp.Diff(want, timestamps(got)); diff != "" {
+		t.Errorf("timestamps after schema update diff -want +got\n%v", diff)
+	}
+
+	// Points are stored in the current encoding.
+	if err := db.db.View(func(tx *bolt.Tx) error {
+		for _, dp := range got {
+			want, err := dp.Encode()
+			if err != nil {
+				return err
+			}
+			windowKey, addrKey, tsKey, err := dpKeys(dp)
+			if err != nil {
+				return err
+			}
+			if got := tx.Bucket([]byte(pointsRoot)).Bucket(windowKey).Bucket(addrKey).Get(tsKey); !bytes.Equal(got, want) {
+				t.Errorf("stored point @ %v = %X, want %X", dp.Timestamp, got, want)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("View failed: %v", err)
+	}
+}
+
+// BenchmarkPoints queries the last day from databases holding a month and a year of data.
+// The cost per query should not depend on the database size.
+func BenchmarkPoints(b *testing.B) {
+	const (
+		tags     = 4
+		interval = 10 * time.Minute
+	)
+	end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, days := range []int{30, 365} {
+		b.Run(fmt.Sprintf("days=%d", days), func(b *testing.B) {
+			db := runDB(b, filepath.Join(b.TempDir(), "db"), false)
+
+			var points []*data.Point
+			for ts := end.Add(-time.Duration(days) * 24 * time.Hour); ts.Before(end); ts = ts.Add(interval) {
+				for i := 0; i < tags; i++ {
+					points = append(points, &data.Point{
+						Address:     fmt.Sprintf("AA:AA:AA:AA:AA:%02X", i),
+						Timestamp:   ts,
+						Temperature: data.Ptr(21.5),
+					})
+				}
+				if len(points) >= 10000 {
+					if err := db.PushPoints(context.Background(), points); err != nil {
+						b.Fatalf("PushPoints failed: %v", err)
+					}
+					points = nil
+				}
+			}
+			if err := db.PushPoints(context.Background(), points); err != nil {
+				b.Fatalf("PushPoints failed: %v", err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				got, err := db.Points(context.Background(), end.Add(-24*time.Hour), end, nil)
+				if err != nil {
+					b.Fatalf("Points failed: %v", err)
+				}
+				// The range excludes its start, and no point was written at its end.
+				if want := tags * (int(24*time.Hour/interval) - 1); len(got) != want {
+					b.Fatalf("Points returned %d points, want %d", len(got), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+// Package database defines the storage backends' interface and selects a backend from the config.
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/s5i/ruuvi2db/data"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	ErrConfig = fmt.Errorf("database config error")
+)
+
+// Database stores data points, aliases and calibrations. Implementations are safe for concurrent use.
+type Database interface {
+	// Run opens the database and maintains it (e.g. applies retention) until ctx is cancelled.
+	// Other methods may be called before Run opens the database; they wait for it.
+	Run(ctx context.Context) error
+
+	PointStore
+	AliasStore
+	CalibrationStore
+}
+
+// PointsWriter stores data points.
+type PointsWriter interface {
+	// PushPoints stores data points. Pushing a point with the same address and timestamp again replaces it.
+	PushPoints(ctx context.Context, points []*data.Point) error
+}
+
+// PointsReader queries data points.
+type PointsReader interface {
+	// Points returns data points between (startTime, endTime].
+	// If addrs isn't empty, only points of these MAC addresses are returned.
+	Points(ctx context.Context, startTime, endTime time.Time, addrs []string) ([]*data.Point, error)
+}
+
+// PointStore stores and queries data points.
+type PointStore interface {
+	PointsWriter
+	PointsReader
+}
+
+// AliasStore stores tag aliases.
+type AliasStore interface {
+	// SetAlias sets an alias for a MAC address; an empty name removes it.
+	SetAlias(ctx context.Context, addr, name string) error
+	// Alias returns the alias for a MAC address, or an empty string.
+	Alias(ctx context.Context, addr string) (string, error)
+	// ListAliases returns aliases keyed by MAC address.
+	ListAliases(ctx context.Context) (map[string]string, error)
+}
+
+// CalibrationStore stores per-tag calibrations.
+type CalibrationStore interface {
+	// SetCalibration adds a calibration, replacing one for the same address, kind and effective-from time.
+	SetCalibration(ctx context.Context, c *data.Calibration) error
+	// DeleteCalibration removes a calibration.
+	DeleteCalibration(ctx context.Context, addr, kind string, effectiveFrom time.Time) error
+	// ListCalibrations returns all calibrations.
+	ListCalibrations(ctx context.Context) ([]*data.Calibration, error)
+}
+
+// Backend returns a Database configured by its section of the config.
+// unmarshal decodes the section into a config struct, as in yaml.Unmarshaler.
+type Backend func(unmarshal func(any) error) (Database, error)
+
+var (
+	backendsMu sync.Mutex
+	backends   = map[string]Backend{}
+)
+
+// Register makes a backend available under name, which is also its config section's key.
+// It's meant to be called from the backend package's init.
+func Register(name string, b Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("database backend %q registered twice", name))
+	}
+	backends[name] = b
+}
+
+// Backends returns the names of registered backends.
+func Backends() []string {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	var ret []string
+	for name := range backends {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Config is the database section of the config. It holds sections keyed by backend name, e.g.:
+//
+//	database:
+//	  backend: bolt  # Optional if there's a single section.
+//	  bolt:
+//	    path: "/appdata/ruuvi2db.db"
+type Config struct {
+	Backend  string
+	sections map[string]any
+}
+
+func (cfg *Config) UnmarshalYAML(unmarshal func(any) error) error {
+	raw := map[string]any{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	cfg.sections = map[string]any{}
+	for k, v := range raw {
+		if k == "backend" {
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("%w: backend must be a string, got %v", ErrConfig, v)
+			}
+			cfg.Backend = s
+			continue
+		}
+		cfg.sections[k] = v
+	}
+	return nil
+}
+
+// backend returns the selected backend's name.
+func (cfg *Config) backend() (string, error) {
+	name := cfg.Backend
+	if name == "" {
+		if len(cfg.sections) != 1 {
+			return "", fmt.Errorf("%w: configure exactly one backend or select one with \"backend\"; available: %q", ErrConfig, Backends())
+		}
+		for k := range cfg.sections {
+			name = k
+		}
+	}
+
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if _, ok := backends[name]; !ok {
+		return "", fmt.Errorf("%w: unknown backend %q", ErrConfig, name)
+	}
+	return name, nil
+}
+
+// Validate checks that a registered backend is selected.
+func (cfg *Config) Validate() error {
+	_, err := cfg.backend()
+	return err
+}
+
+// New returns the selected backend's Database.
+func New(cfg *Config) (Database, error) {
+	name, err := cfg.backend()
+	if err != nil {
+		return nil, err
+	}
+
+	backendsMu.Lock()
+	b := backends[name]
+	backendsMu.Unlock()
+
+	// The section was decoded generically; round-trip it to decode it into the backend's config.
+	section, err := yaml.Marshal(cfg.sections[name])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConfig, err)
+	}
+	db, err := b(func(out any) error { return yaml.Unmarshal(section, out) })
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrConfig, name, err)
+	}
+	return db, nil
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v2"
+)
+
+type fakeConfig struct {
+	Path      string        `yaml:"path"`
+	Retention time.Duration `yaml:"retention"`
+}
+
+type fakeDB struct {
+	Database
+	name string
+	cfg  fakeConfig
+}
+
+func (*fakeDB) Run(context.Context) error { return nil }
+
+func init() {
+	for _, name := range []string{"fake1", "fake2"} {
+		Register(name, func(unmarshal func(any) error) (Database, error) {
+			db := &fakeDB{name: name}
+			if err := unmarshal(&db.cfg); err != nil {
+				return nil, err
+			}
+			return db, nil
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		yaml    string
+		want    *fakeDB
+		wantErr bool
+	}{
+		{
+			name: "single section",
+			yaml: "fake1:\n  path: /db\n  retention: 24h\n",
+			want: &fakeDB{name: "fake1", cfg: fakeConfig{Path: "/db", Retention: 24 * time.Hour}},
+		},
+		{
+			name: "selected section",
+			yaml: "backend: fake2\nfake1:\n  path: /db1\nfake2:\n  path: /db2\n",
+			want: &fakeDB{name: "fake2", cfg: fakeConfig{Path: "/db2"}},
+		},
+		{
+			name: "selected without section",
+			yaml: "backend: fake1\n",
+			want: &fakeDB{name: "fake1"},
+		},
+		{
+			name:    "no section",
+			yaml:    "{}",
+			wantErr: true,
+		},
+		{
+			name:    "ambiguous",
+			yaml:    "fake1: {}\nfake2: {}\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown backend",
+			yaml:    "postgres: {}\n",
+			wantErr: true,
+		},
+		{
+			name:    "malformed section",
+			yaml:    "fake1:\n  retention: soon\n",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{}
+			if err := yaml.Unmarshal([]byte(tc.yaml), cfg); err != nil {
+				t.Fatalf("yaml.Unmarshal failed: %v", err)
+			}
+
+			db, err := New(cfg)
+			if tc.wantErr {
+				if !errors.Is(err, ErrConfig) {
+					t.Errorf("New() error = %v, want %v", err, ErrConfig)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want, db, cmp.AllowUnexported(fakeDB{})); diff != "" {
+				t.Errorf("New() diff -want +got\n%v", diff)
+			}
+		})
+	}
+}
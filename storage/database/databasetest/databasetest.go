@@ -0,0 +1,325 @@
+// Package databasetest is a conformance test suite for database backends.
+package databasetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/storage/database"
+)
+
+// Run runs the suite. newDB returns a new, empty database that hasn't been started.
+func Run(t *testing.T, newDB func(t *testing.T) database.Database) {
+	for _, tc := range []struct {
+		name string
+		f    func(t *testing.T, db database.Database)
+	}{
+		{"Points", testPoints},
+		{"PointFields", testPointFields},
+		{"Aliases", testAliases},
+		{"Calibrations", testCalibrations},
+		{"Cancelled", testCancelled},
+		{"Concurrent", testConcurrent},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newDB(t)
+			start(t, db)
+			tc.f(t, db)
+		})
+	}
+
+	t.Run("CallsBeforeRun", func(t *testing.T) {
+		db := newDB(t)
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- db.PushPoints(context.Background(), []*data.Point{{Address: addrA, Timestamp: time.Unix(100, 0)}})
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		start(t, db)
+		if err := <-errCh; err != nil {
+			t.Errorf("PushPoints before Run failed: %v", err)
+		}
+	})
+}
+
+const (
+	addrA = "AA:AA:AA:AA:AA:AA"
+	addrB = "BB:BB:BB:BB:BB:BB"
+)
+
+// start runs db until the test ends.
+func start(t *testing.T, db database.Database) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- db.Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		if err := <-errCh; err != nil {
+			t.Errorf("Run failed: %v", err)
+		}
+	})
+}
+
+func timestamps(points []*data.Point) []int64 {
+	var ret []int64
+	for _, p := range points {
+		ret = append(ret, p.Timestamp.Unix())
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret
+}
+
+func testPoints(t *testing.T, db database.Database) {
+	ctx := context.Background()
+	const day = int64(24 * time.Hour / time.Second)
+
+	// Points on both sides of day boundaries, including exactly on them.
+	var points []*data.Point
+	for _, ts := range []int64{-10, 0, 10, day - 1, day, day + 1, 2 * day, 5*day + 7} {
+		points = append(points,
+			&data.Point{Address: addrA, Timestamp: time.Unix(ts, 0)},
+			&data.Point{Address: addrB, Timestamp: time.Unix(ts, 0)},
+		)
+	}
+	if err := db.PushPoints(ctx, points); err != nil {
+		t.Fatalf("PushPoints failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		start, end int64
+		addrs      []string
+		want       []int64
+	}{
+		{start: 0, end: day, addrs: []string{addrA}, want: []int64{10, day - 1, day}},
+		{start: -100, end: 0, addrs: []string{addrA}, want: []int64{-10, 0}},
+		{start: day - 1, end: day + 1, addrs: []string{addrB}, want: []int64{day, day + 1}},
+		{start: day, end: 2 * day, want: []int64{day + 1, day + 1, 2 * day, 2 * day}},
+		{start: 2 * day, end: 10 * day, addrs: []string{addrA, addrB}, want: []int64{5*day + 7, 5*day + 7}},
+		{start: 0, end: 10, addrs: []string{"aa:aa:aa:aa:aa:aa"}, want: []int64{10}},
+		{start: 3 * day, end: 4 * day},
+		{start: 0, end: 10 * day, addrs: []string{"CC:CC:CC:CC:CC:CC"}},
+	} {
+		got, err := db.Points(ctx, time.Unix(tc.start, 0), time.Unix(tc.end, 0), tc.addrs)
+		if err != nil {
+			t.Fatalf("Points(%d, %d, %q) failed: %v", tc.start, tc.end, tc.addrs, err)
+		}
+		if diff := cmp.Diff(tc.want, timestamps(got)); diff != "" {
+			t.Errorf("Points(%d, %d, %q) timestamps diff -want +got\n%v", tc.start, tc.end, tc.addrs, diff)
+		}
+	}
+}
+
+func testPointFields(t *testing.T, db database.Database) {
+	ctx := context.Background()
+	ts := time.Unix(1700000000, 0)
+
+	full := &data.Point{
+		Address:             addrA,
+		Timestamp:           ts,
+		Temperature:         data.Ptr(21.5),
+		Humidity:            data.Ptr(45.25),
+		Pressure:            data.Ptr(1013.5),
+		Battery:             data.Ptr(2950.0),
+		AccelerationX:       data.Ptr(0.012),
+		AccelerationY:       data.Ptr(-0.004),
+		AccelerationZ:       data.Ptr(1.0),
+		MovementCounter:     data.Ptr(uint32(12)),
+		MeasurementSequence: data.Ptr(uint32(3456)),
+		RSSI:                data.Ptr(-71),
+		TxPower:             data.Ptr(4),
+	}
+	sparse := &data.Point{
+		Address:     addrB,
+		Timestamp:   ts,
+		Temperature: data.Ptr(-3.0),
+	}
+	// Replaced below.
+	stale := &data.Point{
+		Address:     addrA,
+		Timestamp:   ts,
+		Temperature: data.Ptr(99.0),
+	}
+
+	if err := db.PushPoints(ctx, []*data.Point{stale}); err != nil {
+		t.Fatalf("PushPoints failed: %v", err)
+	}
+	if err := db.PushPoints(ctx, []*data.Point{full, sparse}); err != nil {
+		t.Fatalf("PushPoints failed: %v", err)
+	}
+
+	got, err := db.Points(ctx, ts.Add(-time.Second), ts, nil)
+	if err != nil {
+		t.Fatalf("Points failed: %v", err)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Address < got[j].Address })
+
+	cmpTime := cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })
+	if diff := cmp.Diff([]*data.Point{full, sparse}, got, cmpTime); diff != "" {
+		t.Errorf("Points diff -want +got\n%v", diff)
+	}
+}
+
+func testAliases(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	for _, step := range []struct {
+		addr, name string
+	}{
+		{addrA, "Kitchen"},
+		{addrB, "Garage"},
+		{addrA, "Living room"},
+		{addrB, ""},
+	} {
+		if err := db.SetAlias(ctx, step.addr, step.name); err != nil {
+			t.Fatalf("SetAlias(%q, %q) failed: %v", step.addr, step.name, err)
+		}
+	}
+
+	for addr, want := range map[string]string{addrA: "Living room", addrB: ""} {
+		got, err := db.Alias(ctx, addr)
+		if err != nil {
+			t.Fatalf("Alias(%q) failed: %v", addr, err)
+		}
+		if got != want {
+			t.Errorf("Alias(%q) = %q, want %q", addr, got, want)
+		}
+	}
+
+	got, err := db.ListAliases(ctx)
+	if err != nil {
+		t.Fatalf("ListAliases failed: %v", err)
+	}
+	if diff := cmp.Diff(map[string]string{addrA: "Living room"}, got); diff != "" {
+		t.Errorf("ListAliases diff -want +got\n%v", diff)
+	}
+}
+
+func testCalibrations(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	got, err := db.ListCalibrations(ctx)
+	if err != nil {
+		t.Fatalf("ListCalibrations failed: %v", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Errorf("ListCalibrations of an empty database = %#v, want an empty slice", got)
+	}
+
+	for _, c := range []*data.Calibration{
+		{Address: addrA, Kind: "temperature", EffectiveFrom: time.Unix(0, 0), Offset: -0.4, Scale: 1},
+		{Address: addrA, Kind: "temperature", EffectiveFrom: time.Unix(1000, 0), Offset: -0.5, Scale: 1},
+		{Address: addrA, Kind: "humidity", EffectiveFrom: time.Unix(0, 0), Offset: 0, Scale: 1.03},
+		{Address: addrB, Kind: "temperature", EffectiveFrom: time.Unix(0, 0), Offset: 1, Scale: 1},
+		// Replaces the first one.
+		{Address: addrA, Kind: "temperature", EffectiveFrom: time.Unix(0, 0), Offset: -0.3, Scale: 1},
+	} {
+		if err := db.SetCalibration(ctx, c); err != nil {
+			t.Fatalf("SetCalibration(%+v) failed: %v", c, err)
+		}
+	}
+	if err := db.DeleteCalibration(ctx, addrB, "temperature", time.Unix(0, 0)); err != nil {
+		t.Fatalf("DeleteCalibration failed: %v", err)
+	}
+	// Deleting a missing calibration isn't an error.
+	if err := db.DeleteCalibration(ctx, addrB, "pressure", time.Unix(0, 0)); err != nil {
+		t.Fatalf("DeleteCalibration of a missing calibration failed: %v", err)
+	}
+
+	got, err = db.ListCalibrations(ctx)
+	if err != nil {
+		t.Fatalf("ListCalibrations failed: %v", err)
+	}
+	sort.Slice(got, func(i, j int) bool {
+		if got[i].Kind != got[j].Kind {
+			return got[i].Kind < got[j].Kind
+		}
+		return got[i].EffectiveFrom.Before(got[j].EffectiveFrom)
+	})
+
+	want := []*data.Calibration{
+		{Address: addrA, Kind: "humidity", EffectiveFrom: time.Unix(0, 0), Offset: 0, Scale: 1.03},
+		{Address: addrA, Kind: "temperature", EffectiveFrom: time.Unix(0, 0), Offset: -0.3, Scale: 1},
+		{Address: addrA, Kind: "temperature", EffectiveFrom: time.Unix(1000, 0), Offset: -0.5, Scale: 1},
+	}
+	cmpTime := cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })
+	if diff := cmp.Diff(want, got, cmpTime); diff != "" {
+		t.Errorf("ListCalibrations diff -want +got\n%v", diff)
+	}
+}
+
+func testCancelled(t *testing.T, db database.Database) {
+	var points []*data.Point
+	for i := 0; i < 5000; i++ {
+		points = append(points, &data.Point{Address: addrA, Timestamp: time.Unix(int64(i), 0)})
+	}
+	if err := db.PushPoints(context.Background(), points); err != nil {
+		t.Fatalf("PushPoints failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.Points(ctx, time.Unix(-1, 0), time.Unix(int64(len(points)), 0), nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Points with a cancelled context = %v, want %v", err, context.Canceled)
+	}
+}
+
+func testConcurrent(t *testing.T, db database.Database) {
+	ctx := context.Background()
+	const (
+		writers = 4
+		batches = 20
+	)
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 2*writers)
+	for w := 0; w < writers; w++ {
+		addr := fmt.Sprintf("AA:AA:AA:AA:AA:%02X", w)
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for b := 0; b < batches; b++ {
+				if err := db.PushPoints(ctx, []*data.Point{{Address: addr, Timestamp: time.Unix(int64(b), 0)}}); err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for b := 0; b < batches; b++ {
+				if _, err := db.Points(ctx, time.Unix(-1, 0), time.Unix(batches, 0), []string{addr}); err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Errorf("concurrent access failed: %v", err)
+	}
+
+	got, err := db.Points(ctx, time.Unix(-1, 0), time.Unix(batches, 0), nil)
+	if err != nil {
+		t.Fatalf("Points failed: %v", err)
+	}
+	if len(got) != writers*batches {
+		t.Errorf("Points returned %d points, want %d", len(got), writers*batches)
+	}
+}
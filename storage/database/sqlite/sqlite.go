@@ -0,0 +1,482 @@
+// Package sqlite stores data in an SQLite database, which can also be queried with standard tools.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/storage/database"
+
+	// Pure Go, so that cross-compiling doesn't need cgo.
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	database.Register("sqlite", func(unmarshal func(any) error) (database.Database, error) {
+		cfg := &Config{}
+		if err := unmarshal(cfg); err != nil {
+			return nil, err
+		}
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("path not specified")
+		}
+		cfg.Path = sanitizePath(cfg.Path)
+		return New(cfg), nil
+	})
+}
+
+// Config contains options for SQLite database.
+type Config struct {
+	Path              string        `yaml:"path"`
+	RetentionWindow   time.Duration `yaml:"retention_window"`
+	AllowSchemaUpdate bool          `yaml:"allow_schema_update"`
+}
+
+// New returns an object that can be used to connect and push to SQLite DB.
+func New(cfg *Config) *DB {
+	return &DB{
+		cfg:   cfg,
+		ready: make(chan struct{}),
+	}
+}
+
+// DB is safe for concurrent use. The database runs in WAL mode, so reads don't wait for writes;
+// writes, including retention, are serialized.
+// Calls made before Run has opened the database wait for it.
+type DB struct {
+	cfg   *Config
+	ready chan struct{}
+	db    *sql.DB
+
+	writeMu sync.Mutex
+}
+
+// Run opens the DB and applies retention until ctx is cancelled.
+func (d *DB) Run(ctx context.Context) error {
+	cfg := d.cfg
+
+	q := url.Values{}
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Add("_pragma", "synchronous(NORMAL)")
+	q.Add("_pragma", "busy_timeout(5000)")
+	db, err := sql.Open("sqlite", "file:"+cfg.Path+"?"+q.Encode())
+	if err != nil {
+		return err
+	}
+	// Close waits for running queries to finish.
+	defer db.Close()
+
+	if err := initDB(ctx, db, cfg.AllowSchemaUpdate); err != nil {
+		return err
+	}
+
+	d.db = db
+	close(d.ready)
+
+	var retentionTicker <-chan time.Time
+	if cfg.RetentionWindow > 0 {
+		period := cfg.RetentionWindow / 10
+		if period > time.Hour {
+			period = time.Hour
+		}
+
+		tick := time.NewTicker(period)
+		defer tick.Stop()
+
+		retentionTicker = tick.C
+	}
+
+	for {
+		select {
+		case <-retentionTicker:
+			d.executeRetention(ctx, cfg.RetentionWindow)
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// open returns the SQL DB once Run has opened it.
+func (d *DB) open(ctx context.Context) (*sql.DB, error) {
+	select {
+	case <-d.ready:
+		return d.db, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// update runs fn in a write transaction.
+func (d *DB) update(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	db, err := d.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.writeMu.Lock()
+	defer d.writeMu.Unlock()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PushPoints pushes data points to DB.
+func (d *DB) PushPoints(ctx context.Context, points []*data.Point) error {
+	return d.update(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `INSERT OR REPLACE INTO points (`+pointColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, dp := range points {
+			mac, err := macColumn(dp.Address)
+			if err != nil {
+				return err
+			}
+
+			if _, err := stmt.ExecContext(ctx,
+				mac,
+				dp.Timestamp.UnixNano(),
+				dp.Temperature,
+				dp.Humidity,
+				dp.Pressure,
+				dp.Battery,
+				dp.AccelerationX,
+				dp.AccelerationY,
+				dp.AccelerationZ,
+				dp.MovementCounter,
+				dp.MeasurementSequence,
+				dp.RSSI,
+				dp.TxPower,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Points returns data points between (startTime, endTime].
+// If addrs isn't empty, only points of these MAC addresses are returned.
+// The query stops early if ctx is cancelled.
+func (d *DB) Points(ctx context.Context, startTime, endTime time.Time, addrs []string) ([]*data.Point, error) {
+	db, err := d.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + pointColumns + ` FROM points WHERE ts > ? AND ts <= ?`
+	args := []any{startTime.UnixNano(), endTime.UnixNano()}
+	if len(addrs) > 0 {
+		query += ` AND mac IN (?` + strings.Repeat(`, ?`, len(addrs)-1) + `)`
+		for _, addr := range addrs {
+			mac, err := macColumn(addr)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, mac)
+		}
+	}
+
+	points, err := queryPoints(ctx, db, query, args...)
+	if err != nil {
+		// Cancellation may surface as a driver error.
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return points, nil
+}
+
+func queryPoints(ctx context.Context, db *sql.DB, query string, args ...any) ([]*data.Point, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []*data.Point
+	for rows.Next() {
+		var (
+			mac                                                        string
+			ts                                                         int64
+			temperature, humidity, pressure, battery, accX, accY, accZ sql.NullFloat64
+			movementCounter, measurementSequence, rssi, txPower        sql.NullInt64
+		)
+		if err := rows.Scan(&mac, &ts, &temperature, &humidity, &pressure, &battery, &accX, &accY, &accZ, &movementCounter, &measurementSequence, &rssi, &txPower); err != nil {
+			return nil, err
+		}
+
+		points = append(points, &data.Point{
+			Address:             mac,
+			Timestamp:           time.Unix(0, ts),
+			Temperature:         nullFloat(temperature),
+			Humidity:            nullFloat(humidity),
+			Pressure:            nullFloat(pressure),
+			Battery:             nullFloat(battery),
+			AccelerationX:       nullFloat(accX),
+			AccelerationY:       nullFloat(accY),
+			AccelerationZ:       nullFloat(accZ),
+			MovementCounter:     nullUint32(movementCounter),
+			MeasurementSequence: nullUint32(measurementSequence),
+			RSSI:                nullInt(rssi),
+			TxPower:             nullInt(txPower),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// SetAlias sets an alias for a MAC address.
+func (d *DB) SetAlias(ctx context.Context, addr, name string) error {
+	return d.update(ctx, func(tx *sql.Tx) error {
+		if name == "" {
+			_, err := tx.ExecContext(ctx, `DELETE FROM aliases WHERE addr = ?`, addr)
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO aliases (addr, name) VALUES (?, ?)`, addr, name)
+		return err
+	})
+}
+
+// Alias returns an alias for a MAC address.
+func (d *DB) Alias(ctx context.Context, addr string) (string, error) {
+	db, err := d.open(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var alias string
+	switch err := db.QueryRowContext(ctx, `SELECT name FROM aliases WHERE addr = ?`, addr).Scan(&alias); {
+	case err == sql.ErrNoRows:
+		return "", nil
+	case err != nil:
+		return "", err
+	}
+	return alias, nil
+}
+
+// ListAliases returns aliases of all MAC addresses.
+func (d *DB) ListAliases(ctx context.Context) (map[string]string, error) {
+	db, err := d.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT addr, name FROM aliases`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := map[string]string{}
+	for rows.Next() {
+		var addr, name string
+		if err := rows.Scan(&addr, &name); err != nil {
+			return nil, err
+		}
+		aliases[addr] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// SetCalibration adds a calibration, replacing one for the same address, kind and effective-from time.
+func (d *DB) SetCalibration(ctx context.Context, c *data.Calibration) error {
+	return d.update(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO calibrations (addr, kind, effective_from, offset_value, scale_value) VALUES (?, ?, ?, ?, ?)`,
+			c.Address, c.Kind, c.EffectiveFrom.UnixNano(), c.Offset, c.Scale)
+		return err
+	})
+}
+
+// DeleteCalibration removes a calibration.
+func (d *DB) DeleteCalibration(ctx context.Context, addr, kind string, effectiveFrom time.Time) error {
+	return d.update(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM calibrations WHERE addr = ? AND kind = ? AND effective_from = ?`,
+			addr, kind, effectiveFrom.UnixNano())
+		return err
+	})
+}
+
+// ListCalibrations returns all calibrations.
+func (d *DB) ListCalibrations(ctx context.Context) ([]*data.Calibration, error) {
+	db, err := d.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT addr, kind, effective_from, offset_value, scale_value FROM calibrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calibrations := []*data.Calibration{}
+	for rows.Next() {
+		c := &data.Calibration{}
+		var effectiveFrom int64
+		if err := rows.Scan(&c.Address, &c.Kind, &effectiveFrom, &c.Offset, &c.Scale); err != nil {
+			return nil, err
+		}
+		c.EffectiveFrom = time.Unix(0, effectiveFrom)
+		calibrations = append(calibrations, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return calibrations, nil
+}
+
+func (d *DB) executeRetention(ctx context.Context, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	if err := d.update(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM points WHERE ts <= ?`, time.Now().Add(-retention).UnixNano())
+		return err
+	}); err != nil && ctx.Err() == nil {
+		log.Print(err)
+	}
+}
+
+const pointColumns = `mac, ts, temperature, humidity, pressure, battery, acceleration_x, acceleration_y, acceleration_z, movement_counter, measurement_sequence, rssi, tx_power`
+
+// macColumn returns the canonical form of a MAC address, e.g. AA:BB:CC:DD:EE:FF.
+func macColumn(addr string) (string, error) {
+	mac, err := net.ParseMAC(addr)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(mac.String()), nil
+}
+
+func nullFloat(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return data.Ptr(v.Float64)
+}
+
+func nullInt(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	return data.Ptr(int(v.Int64))
+}
+
+func nullUint32(v sql.NullInt64) *uint32 {
+	if !v.Valid {
+		return nil
+	}
+	return data.Ptr(uint32(v.Int64))
+}
+
+// migrations[i] updates the schema from version i to i+1. The version is kept in PRAGMA user_version.
+// Timestamps are in Unix nanoseconds, e.g. datetime(ts / 1e9, 'unixepoch') makes them readable.
+var migrations = []string{
+	`
+CREATE TABLE points (
+	mac                  TEXT NOT NULL,
+	ts                   INTEGER NOT NULL,
+	temperature          REAL,
+	humidity             REAL,
+	pressure             REAL,
+	battery              REAL,
+	acceleration_x       REAL,
+	acceleration_y       REAL,
+	acceleration_z       REAL,
+	movement_counter     INTEGER,
+	measurement_sequence INTEGER,
+	rssi                 INTEGER,
+	tx_power             INTEGER,
+	PRIMARY KEY (mac, ts)
+) WITHOUT ROWID;
+
+CREATE INDEX points_ts ON points (ts);
+
+CREATE TABLE aliases (
+	addr TEXT PRIMARY KEY,
+	name TEXT NOT NULL
+);
+
+CREATE TABLE calibrations (
+	addr           TEXT NOT NULL,
+	kind           TEXT NOT NULL,
+	effective_from INTEGER NOT NULL,
+	offset_value   REAL NOT NULL,
+	scale_value    REAL NOT NULL,
+	PRIMARY KEY (addr, kind, effective_from)
+);
+`,
+}
+
+func initDB(ctx context.Context, db *sql.DB, allowSchemaUpdate bool) error {
+	var v int
+	if err := db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&v); err != nil {
+		return err
+	}
+
+	switch {
+	case v == len(migrations):
+		return nil
+	case v > len(migrations):
+		return fmt.Errorf("detected DB schema version %d, newer than supported %d", v, len(migrations))
+	case v > 0 && !allowSchemaUpdate:
+		return fmt.Errorf("detected old DB schema version %d, want %d; requires AllowSchemaUpdate to proceed", v, len(migrations))
+	}
+
+	for ; v < len(migrations); v++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[v]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("updating DB schema to version %d: %v", v+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, v+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sanitizePath(path string) string {
+	if x, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(os.Getenv("HOME"), x)
+	}
+
+	return path
+}
@@ -0,0 +1,89 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/s5i/ruuvi2db/storage/database"
+	"github.com/s5i/ruuvi2db/storage/database/databasetest"
+)
+
+func TestConformance(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T) database.Database {
+		return New(&Config{Path: filepath.Join(t.TempDir(), "db")})
+	})
+}
+
+func TestSchemaVersion(t *testing.T) {
+	for _, tc := range []struct {
+		desc              string
+		version           int
+		allowSchemaUpdate bool
+		wantErr           bool
+	}{
+		{
+			desc:    "new",
+			version: 0,
+		},
+		{
+			desc:    "current",
+			version: len(migrations),
+		},
+		{
+			desc:    "newer",
+			version: len(migrations) + 1,
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "db")
+
+			if tc.version > 0 {
+				raw, err := sql.Open("sqlite", path)
+				if err != nil {
+					t.Fatalf("sql.Open failed: %v", err)
+				}
+				if tc.version == len(migrations) {
+					if err := initDB(context.Background(), raw, false); err != nil {
+						t.Fatalf("initDB failed: %v", err)
+					}
+				} else if _, err := raw.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, tc.version)); err != nil {
+					t.Fatalf("setting user_version failed: %v", err)
+				}
+				raw.Close()
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			db := New(&Config{Path: path, AllowSchemaUpdate: tc.allowSchemaUpdate})
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- db.Run(ctx)
+			}()
+
+			select {
+			case err := <-errCh:
+				if !tc.wantErr {
+					t.Fatalf("Run failed: %v", err)
+				}
+				return
+			case <-db.ready:
+			}
+			if tc.wantErr {
+				t.Fatalf("Run succeeded, want error")
+			}
+
+			if _, err := db.ListAliases(ctx); err != nil {
+				t.Errorf("ListAliases failed: %v", err)
+			}
+			cancel()
+			if err := <-errCh; err != nil {
+				t.Errorf("Run failed: %v", err)
+			}
+		})
+	}
+}
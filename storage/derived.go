@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"math"
+	"slices"
+
+	"github.com/s5i/ruuvi2db/data"
+)
+
+// derivedKinds are computed from temperature, humidity and pressure rather than stored.
+var derivedKinds = []string{"dew_point", "absolute_humidity", "vpd", "heat_index", "air_density"}
+
+// derivedPoint is a data point along with the derived kinds computed for it.
+// Derived kinds only exist at query time, so they're kept out of data.Point.
+type derivedPoint struct {
+	*data.Point
+
+	// derived maps derived kinds to their values; kinds that can't be computed for the point are missing.
+	derived map[string]float64
+}
+
+// derive returns points with the given derived kinds computed.
+// Kinds that can't be computed for a point, e.g. for lack of humidity, are left out.
+func derive(src []*data.Point, kinds []string) []*derivedPoint {
+	var want []string
+	for _, k := range kinds {
+		if slices.Contains(derivedKinds, k) {
+			want = append(want, k)
+		}
+	}
+
+	ret := make([]*derivedPoint, 0, len(src))
+	for _, p := range src {
+		out := &derivedPoint{Point: p}
+		for _, k := range want {
+			v, ok := derivedValue(p, k)
+			if !ok {
+				continue
+			}
+			if out.derived == nil {
+				out.derived = map[string]float64{}
+			}
+			out.derived[k] = v
+		}
+		ret = append(ret, out)
+	}
+	return ret
+}
+
+func derivedValue(p *data.Point, kind string) (float64, bool) {
+	if p.Temperature == nil {
+		return 0, false
+	}
+	t := *p.Temperature
+
+	if kind == "air_density" {
+		if p.Pressure == nil {
+			return 0, false
+		}
+		rh := 0.0
+		if p.Humidity != nil {
+			rh = *p.Humidity
+		}
+		return airDensity(t, rh, *p.Pressure), true
+	}
+
+	if p.Humidity == nil {
+		return 0, false
+	}
+	rh := *p.Humidity
+
+	switch kind {
+	case "dew_point":
+		if rh <= 0 {
+			return 0, false
+		}
+		return dewPoint(t, rh), true
+	case "absolute_humidity":
+		return absoluteHumidity(t, rh), true
+	case "vpd":
+		return vpd(t, rh), true
+	case "heat_index":
+		return heatIndex(t, rh), true
+	}
+	return 0, false
+}
+
+// Magnus formula coefficients over water (Sonntag 1990).
+const (
+	magnusA = 6.112  // hPa
+	magnusB = 17.62  // dimensionless
+	magnusC = 243.12 // °C
+)
+
+// saturationVapourPressure returns the saturation vapour pressure in hPa at temperature t in °C.
+func saturationVapourPressure(t float64) float64 {
+	return magnusA * math.Exp(magnusB*t/(magnusC+t))
+}
+
+// dewPoint returns the dew point in °C.
+func dewPoint(t, rh float64) float64 {
+	g := math.Log(rh/100) + magnusB*t/(magnusC+t)
+	return magnusC * g / (magnusB - g)
+}
+
+// absoluteHumidity returns water vapour density in g/m³.
+func absoluteHumidity(t, rh float64) float64 {
+	e := saturationVapourPressure(t) * rh / 100
+	// e [hPa] * 100 * M_w [g/mol] / (R [J/(mol K)] * T [K]).
+	return e * 100 * 18.01528 / (8.314462618 * (t + 273.15))
+}
+
+// vpd returns the vapour pressure deficit in kPa.
+func vpd(t, rh float64) float64 {
+	return saturationVapourPressure(t) * (1 - rh/100) / 10
+}
+
+// heatIndex returns the apparent temperature in °C, as computed by the US National Weather Service.
+func heatIndex(t, rh float64) float64 {
+	f := t*9/5 + 32
+
+	hi := 0.5 * (f + 61 + (f-68)*1.2 + rh*0.094)
+	if (hi+f)/2 >= 80 {
+		hi = -42.379 + 2.04901523*f + 10.14333127*rh - 0.22475541*f*rh - 0.00683783*f*f - 0.05481717*rh*rh + 0.00122874*f*f*rh + 0.00085282*f*rh*rh - 0.00000199*f*f*rh*rh
+		switch {
+		case rh < 13 && f >= 80 && f <= 112:
+			hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(f-95))/17)
+		case rh > 85 && f >= 80 && f <= 87:
+			hi += (rh - 85) / 10 * (87 - f) / 5
+		}
+	}
+
+	return (hi - 32) * 5 / 9
+}
+
+// airDensity returns the density of moist air in kg/m³ given pressure in hPa.
+func airDensity(t, rh, pressure float64) float64 {
+	const (
+		rDry    = 287.058 // J/(kg K)
+		rVapour = 461.495 // J/(kg K)
+	)
+	tk := t + 273.15
+	pv := saturationVapourPressure(t) * rh / 100 * 100
+	pd := pressure*100 - pv
+	return pd/(rDry*tk) + pv/(rVapour*tk)
+}
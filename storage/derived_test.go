@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func TestDerive(t *testing.T) {
+	p := &data.Point{
+		Address:     "AA:AA:AA:AA:AA:AA",
+		Timestamp:   time.Unix(100, 0),
+		Temperature: data.Ptr(20.0),
+		Humidity:    data.Ptr(50.0),
+		Pressure:    data.Ptr(1013.25),
+	}
+	hot := &data.Point{
+		Temperature: data.Ptr(32.2),
+		Humidity:    data.Ptr(70.0),
+	}
+	dry := &data.Point{
+		Temperature: data.Ptr(20.0),
+		Humidity:    data.Ptr(0.0),
+		Pressure:    data.Ptr(1013.25),
+	}
+
+	for _, tc := range []struct {
+		kind   string
+		in     *data.Point
+		want   float64
+		wantOk bool
+	}{
+		{kind: "dew_point", in: p, want: 9.26, wantOk: true},
+		{kind: "dew_point", in: dry, wantOk: false},
+		{kind: "absolute_humidity", in: p, want: 8.64, wantOk: true},
+		{kind: "vpd", in: p, want: 1.17, wantOk: true},
+		{kind: "heat_index", in: p, want: 19.36, wantOk: true},
+		// NWS heat index table: 90 °F at 70% is about 106 °F.
+		{kind: "heat_index", in: hot, want: 41.0, wantOk: true},
+		{kind: "air_density", in: dry, want: 1.204, wantOk: true},
+		{kind: "air_density", in: hot, wantOk: false},
+	} {
+		got := derive([]*data.Point{tc.in}, []string{tc.kind})[0]
+		v, ok := got.derived[tc.kind]
+		if ok != tc.wantOk {
+			t.Errorf("%s of %v: ok = %v, want %v", tc.kind, tc.in, ok, tc.wantOk)
+			continue
+		}
+		if ok && math.Abs(v-tc.want) > 0.05 {
+			t.Errorf("%s of %v = %.3f, want %.3f", tc.kind, tc.in, v, tc.want)
+		}
+	}
+}
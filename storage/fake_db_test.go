@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/storage/database"
+)
+
+// fakeDB is an in-memory database for handler tests.
+// Points returns points regardless of the queried range and records the queried addresses.
+type fakeDB struct {
+	database.Database // Methods not overridden below panic.
+
+	mu           sync.Mutex
+	points       []*data.Point
+	pushed       []*data.Point
+	queriedAddrs []string
+	aliases      map[string]string
+	cals         []*data.Calibration
+
+	// onPush, if set, is called after points are pushed.
+	onPush func()
+}
+
+func (f *fakeDB) PushPoints(ctx context.Context, points []*data.Point) error {
+	f.mu.Lock()
+	f.pushed = append(f.pushed, points...)
+	f.mu.Unlock()
+
+	if f.onPush != nil {
+		f.onPush()
+	}
+	return nil
+}
+
+func (f *fakeDB) Points(ctx context.Context, startTime, endTime time.Time, addrs []string) ([]*data.Point, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.queriedAddrs = addrs
+	return f.points, nil
+}
+
+func (f *fakeDB) ListAliases(ctx context.Context) (map[string]string, error) {
+	return f.aliases, nil
+}
+
+func (f *fakeDB) ListCalibrations(ctx context.Context) ([]*data.Calibration, error) {
+	return f.cals, nil
+}
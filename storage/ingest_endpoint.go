@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/storage/database"
+)
+
+type RunIngestEndpointOpts struct {
+	Listen    string
+	MACFilter []string
+	Points    database.PointsWriter
+}
+
+// RunIngestEndpoint accepts batches of points pushed by readers.
+func RunIngestEndpoint(ctx context.Context, opts *RunIngestEndpointOpts) error {
+	srv := http.Server{}
+	srv.Addr = opts.Listen
+
+	srv.ReadTimeout = time.Minute
+	srv.WriteTimeout = time.Minute
+
+	mux := http.NewServeMux()
+	mux.Handle("/ingest", IngestHandler(&IngestHandlerOpts{
+		MACFilter: opts.MACFilter,
+		Points:    opts.Points,
+	}))
+
+	srv.Handler = mux
+
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(ctx)
+	}()
+
+	switch err := srv.ListenAndServe(); {
+	case errors.Is(err, http.ErrServerClosed):
+		return nil
+	default:
+		return err
+	}
+}
+
+type IngestHandlerOpts struct {
+	MACFilter []string
+	Points    database.PointsWriter
+}
+
+// maxBatchBytes bounds the size of a batch; the default of 500 points takes well under 1 MiB.
+const maxBatchBytes = 16 << 20
+
+// IngestHandler stores a data.Batch sent as JSON.
+// The batch ID isn't tracked: databases key points by address and timestamp, so storing a batch that's
+// delivered more than once overwrites the same points.
+// Points with a malformed address or no timestamp are skipped rather than failing the batch.
+func IngestHandler(opts *IngestHandlerOpts) http.HandlerFunc {
+	filter := map[string]bool{}
+	for _, mac := range opts.MACFilter {
+		filter[strings.ToUpper(mac)] = true
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST required", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var b data.Batch
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBytes)).Decode(&b); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("batch larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, fmt.Sprintf("malformed batch: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		var points []*data.Point
+		for _, p := range b.Points {
+			if p == nil || p.Timestamp.IsZero() {
+				continue
+			}
+			if _, err := net.ParseMAC(p.Address); err != nil {
+				continue
+			}
+			if len(filter) > 0 && !filter[strings.ToUpper(p.Address)] {
+				continue
+			}
+			points = append(points, p)
+		}
+
+		if err := opts.Points.PushPoints(r.Context(), points); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIngestHandler(t *testing.T) {
+	db := &fakeDB{}
+	h := IngestHandler(&IngestHandlerOpts{
+		MACFilter: []string{"aa:aa:aa:aa:aa:aa", "BB:BB:BB:BB:BB:BB"},
+		Points:    db,
+	})
+
+	for _, tc := range []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		want       []string
+	}{
+		{
+			name:   "filters points",
+			method: http.MethodPost,
+			body: `{"ID": "1", "Points": [
+  {"Address": "AA:AA:AA:AA:AA:AA", "Timestamp": "2024-01-01T00:00:00Z", "Temperature": 21.5},
+  {"Address": "CC:CC:CC:CC:CC:CC", "Timestamp": "2024-01-01T00:00:00Z"},
+  {"Address": "BB:BB:BB:BB:BB:BB"},
+  {"Address": "not a mac", "Timestamp": "2024-01-01T00:00:00Z"}
+]}`,
+			wantStatus: http.StatusOK,
+			want:       []string{"AA:AA:AA:AA:AA:AA"},
+		},
+		{
+			name:       "malformed",
+			method:     http.MethodPost,
+			body:       `{"Points": "nope"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too large",
+			method:     http.MethodPost,
+			body:       `{"ID": "1", "Points": [` + strings.Repeat(`{"Address": "AA:AA:AA:AA:AA:AA"},`, maxBatchBytes/32) + `]}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db.pushed = nil
+
+			w := httptest.NewRecorder()
+			h(w, httptest.NewRequest(tc.method, "/ingest", strings.NewReader(tc.body)))
+			if got, want := w.Code, tc.wantStatus; got != want {
+				t.Fatalf("status = %d, want %d (%s)", got, want, w.Body)
+			}
+			var got []string
+			for _, p := range db.pushed {
+				got = append(got, p.Address)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("pushed points diff -want +got\n%v", diff)
+			}
+		})
+	}
+}
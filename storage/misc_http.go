@@ -2,7 +2,10 @@ package storage
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 func singleStringParam(r *http.Request, p string) (string, bool, error) {
@@ -18,3 +21,34 @@ func singleStringParam(r *http.Request, p string) (string, bool, error) {
 	return x[0], true, nil
 
 }
+
+// floatParam returns a finite float parameter, or def if it's not specified.
+func floatParam(r *http.Request, p string, def float64) (float64, error) {
+	x, ok, err := singleStringParam(r, p)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return def, nil
+	}
+
+	ret, err := strconv.ParseFloat(x, 64)
+	if err != nil || math.IsNaN(ret) || math.IsInf(ret, 0) {
+		return 0, fmt.Errorf("malformed %s %q", p, x)
+	}
+
+	return ret, nil
+}
+
+// listParam returns all values of a repeated or comma-separated query parameter.
+func listParam(r *http.Request, p string) []string {
+	var ret []string
+	for _, v := range r.URL.Query()[p] {
+		for _, x := range strings.Split(v, ",") {
+			if x = strings.TrimSpace(x); x != "" {
+				ret = append(ret, x)
+			}
+		}
+	}
+	return ret
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/s5i/ruuvi2db/data"
+	"github.com/s5i/ruuvi2db/storage/database"
 )
 
 type RunReaderConsumerOpts struct {
@@ -17,7 +18,12 @@ type RunReaderConsumerOpts struct {
 	QueryPeriod  time.Duration
 	MaxStaleness time.Duration
 	MACFilter    []string
-	PushPointsF  func([]*data.Point) error
+	Points       database.PointStore
+
+	// BackfillWindow, if set, makes the consumer fetch everything the reader saw since the last fetched point,
+	// looking back at most BackfillWindow, so that outages of either side don't leave gaps.
+	// At startup, fetching resumes from the latest stored point.
+	BackfillWindow time.Duration
 }
 
 func RunReaderConsumer(ctx context.Context, opts *RunReaderConsumerOpts) error {
@@ -31,38 +37,61 @@ func RunReaderConsumer(ctx context.Context, opts *RunReaderConsumerOpts) error {
 		filter[strings.ToUpper(mac)] = true
 	}
 
+	var since time.Time
+	if opts.BackfillWindow > 0 {
+		since = latestStored(ctx, opts)
+	}
+
 	tick := time.NewTicker(opts.QueryPeriod)
 	for {
 		func() {
-			resp, err := http.Get(endpoint)
+			u := endpoint
+			if opts.BackfillWindow > 0 {
+				since = maxTime(since, time.Now().Add(-opts.BackfillWindow))
+				u += "?since=" + url.QueryEscape(since.Format(time.RFC3339Nano))
+			}
+
+			resp, err := http.Get(u)
 			if err != nil {
 				return
 			}
 			defer resp.Body.Close()
 
+			if resp.StatusCode != http.StatusOK {
+				log.Printf("reader %s: %s", u, resp.Status)
+				return
+			}
+
 			var src, dst []*data.Point
 			d := json.NewDecoder(resp.Body)
 			if err := d.Decode(&src); err != nil {
 				return
 			}
 
+			newest := since
 			for _, p := range src {
+				newest = maxTime(newest, p.Timestamp)
+
 				if len(opts.MACFilter) > 0 && !filter[strings.ToUpper(p.Address)] {
 					continue
 				}
 
-				p.Timestamp = p.Timestamp.Truncate(opts.QueryPeriod)
-				if p.Timestamp.Add(opts.MaxStaleness).Before(time.Now()) {
-					continue
+				// Snapshots keep at most one point per tag per period; backfilled history keeps every point.
+				if opts.BackfillWindow == 0 {
+					p.Timestamp = p.Timestamp.Truncate(opts.QueryPeriod)
+					if p.Timestamp.Add(opts.MaxStaleness).Before(time.Now()) {
+						continue
+					}
 				}
 
 				dst = append(dst, p)
 			}
 
-			if err := opts.PushPointsF(dst); err != nil {
+			if err := opts.Points.PushPoints(ctx, dst); err != nil {
 				log.Print(err)
 				return
 			}
+			since = newest
 		}()
 
 		select {
@@ -72,3 +101,26 @@ func RunReaderConsumer(ctx context.Context, opts *RunReaderConsumerOpts) error {
 		}
 	}
 }
+
+// latestStored returns the timestamp of the newest stored point within the backfill window, or zero time.
+func latestStored(ctx context.Context, opts *RunReaderConsumerOpts) time.Time {
+	now := time.Now()
+	points, err := opts.Points.Points(ctx, now.Add(-opts.BackfillWindow), now, nil)
+	if err != nil {
+		log.Print(err)
+		return time.Time{}
+	}
+
+	var ret time.Time
+	for _, p := range points {
+		ret = maxTime(ret, p.Timestamp)
+	}
+	return ret
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
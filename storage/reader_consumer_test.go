@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/s5i/ruuvi2db/data"
+)
+
+func TestRunReaderConsumerBackfill(t *testing.T) {
+	now := time.Now()
+	stored := now.Add(-30 * time.Minute).Truncate(time.Minute)
+
+	var gotSince []string
+	reader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSince = append(gotSince, r.URL.Query().Get("since"))
+		json.NewEncoder(w).Encode([]*data.Point{
+			{Address: "AA:AA:AA:AA:AA:AA", Timestamp: now.Add(-20 * time.Minute)},
+			{Address: "AA:AA:AA:AA:AA:AA", Timestamp: now.Add(-20*time.Minute + 10*time.Second)},
+			{Address: "AA:AA:AA:AA:AA:AA", Timestamp: now.Add(-10 * time.Second)},
+		})
+	}))
+	defer reader.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := &fakeDB{
+		points: []*data.Point{{Address: "AA:AA:AA:AA:AA:AA", Timestamp: stored}},
+		onPush: cancel,
+	}
+	if err := RunReaderConsumer(ctx, &RunReaderConsumerOpts{
+		ReaderAddr:     strings.TrimPrefix(reader.URL, "http://"),
+		QueryPeriod:    time.Minute,
+		MaxStaleness:   2 * time.Minute,
+		Points:         db,
+		BackfillWindow: time.Hour,
+	}); err != nil {
+		t.Fatalf("RunReaderConsumer failed: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{stored.Format(time.RFC3339Nano)}, gotSince); diff != "" {
+		t.Errorf("since diff -want +got\n%v", diff)
+	}
+
+	// Points older than MaxStaleness are kept when backfilling, as are several points within one period.
+	want := []time.Time{now.Add(-20 * time.Minute), now.Add(-20*time.Minute + 10*time.Second), now.Add(-10 * time.Second)}
+	var got []time.Time
+	for _, p := range db.pushed {
+		got = append(got, p.Timestamp)
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateApproxTime(0)); diff != "" {
+		t.Errorf("pushed timestamps diff -want +got\n%v", diff)
+	}
+}
+
+func TestRunReaderConsumerErrorStatus(t *testing.T) {
+	reader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode([]*data.Point{{Address: "AA:AA:AA:AA:AA:AA", Timestamp: time.Now()}})
+	}))
+	defer reader.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	db := &fakeDB{}
+	if err := RunReaderConsumer(ctx, &RunReaderConsumerOpts{
+		ReaderAddr:   strings.TrimPrefix(reader.URL, "http://"),
+		QueryPeriod:  10 * time.Millisecond,
+		MaxStaleness: time.Minute,
+		Points:       db,
+	}); err != nil {
+		t.Fatalf("RunReaderConsumer failed: %v", err)
+	}
+
+	if pushed := len(db.pushed); pushed != 0 {
+		t.Errorf("pushed %d points from an error response, want 0", pushed)
+	}
+}
@@ -3,19 +3,23 @@ package storage
 import (
 	"context"
 
-	"github.com/s5i/ruuvi2db/storage/database/bolt"
+	"github.com/s5i/ruuvi2db/storage/database"
 	"golang.org/x/sync/errgroup"
+
+	// Backends register themselves with the database package.
+	_ "github.com/s5i/ruuvi2db/storage/database/bolt"
+	_ "github.com/s5i/ruuvi2db/storage/database/sqlite"
 )
 
 func Run(ctx context.Context, g *errgroup.Group, cfg *Config) {
-	db := bolt.New()
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		g.Go(func() error { return err })
+		return
+	}
 
 	g.Go(func() error {
-		return db.Run(ctx, &bolt.Config{
-			Path:              cfg.Database.Bolt.Path,
-			RetentionWindow:   cfg.Database.Bolt.RetentionWindow,
-			AllowSchemaUpdate: cfg.Database.Bolt.AllowSchemaUpdate,
-		})
+		return db.Run(ctx)
 	})
 
 	if cfg.ConsumedEndpoints.Reader != "" {
@@ -25,7 +29,9 @@ func Run(ctx context.Context, g *errgroup.Group, cfg *Config) {
 				QueryPeriod:  cfg.ReaderConsumer.QueryPeriod,
 				MaxStaleness: cfg.ReaderConsumer.MaxStaleness,
 				MACFilter:    cfg.ReaderConsumer.MACFilter,
-				PushPointsF:  db.PushPoints,
+				Points:       db,
+
+				BackfillWindow: cfg.ReaderConsumer.BackfillWindow,
 			})
 		})
 	}
@@ -34,9 +40,19 @@ func Run(ctx context.Context, g *errgroup.Group, cfg *Config) {
 		g.Go(func() error {
 			return RunDataEndpoint(ctx, &RunDataEndpointOpts{
 				Listen:       cfg.ProvidedEndpoints.Data,
-				PointsF:      db.Points,
-				AliasF:       db.Alias,
-				ListAliasesF: db.ListAliases,
+				Points:       db,
+				Aliases:      db,
+				Calibrations: db,
+			})
+		})
+	}
+
+	if cfg.ProvidedEndpoints.Ingest != "" {
+		g.Go(func() error {
+			return RunIngestEndpoint(ctx, &RunIngestEndpointOpts{
+				Listen:    cfg.ProvidedEndpoints.Ingest,
+				MACFilter: cfg.Ingest.MACFilter,
+				Points:    db,
 			})
 		})
 	}
@@ -44,8 +60,9 @@ func Run(ctx context.Context, g *errgroup.Group, cfg *Config) {
 	if cfg.ProvidedEndpoints.Admin != "" {
 		g.Go(func() error {
 			return RunAdminEndpoint(ctx, &RunAdminEndpointOpts{
-				Listen:    cfg.ProvidedEndpoints.Admin,
-				SetAliasF: db.SetAlias,
+				Listen:       cfg.ProvidedEndpoints.Admin,
+				Aliases:      db,
+				Calibrations: db,
 			})
 		})
 	}
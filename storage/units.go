@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// Unit is a unit a kind can be reported in.
+type Unit struct {
+	ID     string `json:"id"`
+	Symbol string `json:"symbol"`
+
+	format string
+	// fromBase converts from the quantity's base unit; toBase is its inverse.
+	fromBase func(float64) float64
+	toBase   func(float64) float64
+}
+
+func (u *Unit) Format(v float64) string {
+	return fmt.Sprintf(u.format, v)
+}
+
+// scaled is a unit equal to factor base units.
+func scaled(id, symbol, format string, factor float64) *Unit {
+	return &Unit{
+		ID:       id,
+		Symbol:   symbol,
+		format:   format,
+		fromBase: func(v float64) float64 { return v / factor },
+		toBase:   func(v float64) float64 { return v * factor },
+	}
+}
+
+// batteryEmpty and batteryFull are the voltages (in mV) mapped to 0% and 100%.
+// A CR2477 stays close to 3 V for most of its life; below 2.5 V the tag may stop working in the cold.
+const (
+	batteryEmpty = 2500
+	batteryFull  = 3000
+)
+
+// quantityUnits lists the units of each quantity; the first one is the base unit.
+var quantityUnits = map[string][]*Unit{
+	"temperature": {
+		scaled("C", "°C", "%.2f", 1),
+		{
+			ID: "F", Symbol: "°F", format: "%.2f",
+			fromBase: func(v float64) float64 { return v*9/5 + 32 },
+			toBase:   func(v float64) float64 { return (v - 32) * 5 / 9 },
+		},
+		{
+			ID: "K", Symbol: "K", format: "%.2f",
+			fromBase: func(v float64) float64 { return v + 273.15 },
+			toBase:   func(v float64) float64 { return v - 273.15 },
+		},
+	},
+	"humidity": {
+		scaled("%", "%", "%.2f", 1),
+	},
+	"pressure": {
+		scaled("Pa", "Pa", "%.0f", 1),
+		scaled("hPa", "hPa", "%.2f", 100),
+		scaled("kPa", "kPa", "%.3f", 1000),
+		scaled("mmHg", "mmHg", "%.2f", 133.322387415),
+		scaled("inHg", "inHg", "%.3f", 3386.389),
+		scaled("psi", "psi", "%.4f", 6894.757),
+	},
+	// VPD is a pressure, but it's conventionally given in kPa regardless of the unit used for air pressure,
+	// so it's a quantity of its own.
+	"vpd": {
+		scaled("kPa", "kPa", "%.3f", 1),
+		scaled("hPa", "hPa", "%.2f", 0.1),
+		scaled("Pa", "Pa", "%.0f", 0.001),
+		scaled("psi", "psi", "%.4f", 6.894757),
+	},
+	"battery": {
+		scaled("mV", "mV", "%.2f", 1),
+		scaled("V", "V", "%.3f", 1000),
+		{
+			ID: "%", Symbol: "%", format: "%.0f",
+			fromBase: func(v float64) float64 {
+				return math.Max(0, math.Min(100, (v-batteryEmpty)/(batteryFull-batteryEmpty)*100))
+			},
+			toBase: func(v float64) float64 { return batteryEmpty + v/100*(batteryFull-batteryEmpty) },
+		},
+	},
+	"acceleration": {
+		scaled("g", "g", "%.3f", 1),
+		scaled("m/s2", "m/s²", "%.3f", 1/9.80665),
+	},
+	"absolute_humidity": {
+		scaled("g/m3", "g/m³", "%.2f", 1),
+		scaled("gr/ft3", "gr/ft³", "%.3f", 1/0.4369957),
+	},
+	"air_density": {
+		scaled("kg/m3", "kg/m³", "%.4f", 1),
+		scaled("lb/ft3", "lb/ft³", "%.5f", 16.01846),
+	},
+	"signal": {
+		scaled("dBm", "dBm", "%.0f", 1),
+	},
+}
+
+// kindUnits maps each kind with a unit to its quantity and the unit values are stored in.
+var kindUnits = map[string]struct{ quantity, stored string }{
+	"temperature":       {"temperature", "C"},
+	"humidity":          {"humidity", "%"},
+	"pressure":          {"pressure", "hPa"},
+	"battery":           {"battery", "mV"},
+	"acceleration_x":    {"acceleration", "g"},
+	"acceleration_y":    {"acceleration", "g"},
+	"acceleration_z":    {"acceleration", "g"},
+	"dew_point":         {"temperature", "C"},
+	"absolute_humidity": {"absolute_humidity", "g/m3"},
+	"vpd":               {"vpd", "kPa"},
+	"heat_index":        {"temperature", "C"},
+	"air_density":       {"air_density", "kg/m3"},
+	"rssi":              {"signal", "dBm"},
+	"tx_power":          {"signal", "dBm"},
+}
+
+// unitSystems give the units of each quantity or kind in a system; others keep their stored unit.
+var unitSystems = map[string]map[string]string{
+	"metric": {},
+	"imperial": {
+		"temperature":       "F",
+		"pressure":          "inHg",
+		"absolute_humidity": "gr/ft3",
+		"air_density":       "lb/ft3",
+	},
+}
+
+// Units selects the units of each kind.
+type Units struct {
+	system    map[string]string
+	overrides map[string]string
+}
+
+// Unit returns the unit kind should be reported in, or nil for kinds without a unit (e.g. counters).
+func (u *Units) Unit(kind string) *Unit {
+	ku, ok := kindUnits[kind]
+	if !ok {
+		return nil
+	}
+
+	// More specific choices win.
+	id := ku.stored
+	for _, m := range []map[string]string{u.system, u.overrides} {
+		for _, k := range []string{ku.quantity, kind} {
+			if x, ok := m[k]; ok {
+				id = x
+			}
+		}
+	}
+	return findUnit(ku.quantity, id)
+}
+
+// Convert converts v of kind from its stored unit.
+func (u *Units) Convert(kind string, v float64) float64 {
+	ku, ok := kindUnits[kind]
+	if !ok {
+		return v
+	}
+	return u.Unit(kind).fromBase(findUnit(ku.quantity, ku.stored).toBase(v))
+}
+
+func findUnit(quantity, id string) *Unit {
+	for _, u := range quantityUnits[quantity] {
+		if u.ID == id {
+			return u
+		}
+	}
+	return nil
+}
+
+// dataUnits parses the "units" parameter: a comma-separated list of a system (metric or imperial)
+// and per-quantity or per-kind overrides, e.g. "imperial,pressure=hPa" or "temperature=F".
+func dataUnits(r *http.Request) (*Units, error) {
+	ret := &Units{
+		system:    unitSystems["metric"],
+		overrides: map[string]string{},
+	}
+
+	for _, x := range listParam(r, "units") {
+		k, id, ok := strings.Cut(x, "=")
+		if !ok {
+			s, ok := unitSystems[x]
+			if !ok {
+				return nil, fmt.Errorf("unrecognized unit system %q; valid: metric, imperial", x)
+			}
+			ret.system = s
+			continue
+		}
+
+		quantity := k
+		if ku, ok := kindUnits[k]; ok {
+			quantity = ku.quantity
+		}
+		if _, ok := quantityUnits[quantity]; !ok {
+			return nil, fmt.Errorf("unrecognized quantity %q", k)
+		}
+		if findUnit(quantity, id) == nil {
+			var valid []string
+			for _, u := range quantityUnits[quantity] {
+				valid = append(valid, u.ID)
+			}
+			return nil, fmt.Errorf("unrecognized %s unit %q; valid: %q", k, id, valid)
+		}
+		ret.overrides[k] = id
+	}
+
+	return ret, nil
+}
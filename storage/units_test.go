@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestDataUnits(t *testing.T) {
+	for _, tc := range []struct {
+		units   string
+		kind    string
+		in      float64
+		want    string
+		wantErr bool
+	}{
+		{units: "", kind: "temperature", in: 20, want: "20.00 °C"},
+		{units: "metric", kind: "pressure", in: 1013.25, want: "1013.25 hPa"},
+		{units: "imperial", kind: "temperature", in: 20, want: "68.00 °F"},
+		{units: "imperial", kind: "dew_point", in: -40, want: "-40.00 °F"},
+		{units: "imperial", kind: "pressure", in: 1013.25, want: "29.921 inHg"},
+		{units: "imperial", kind: "vpd", in: 1.5, want: "1.500 kPa"},
+		{units: "imperial,pressure=hPa", kind: "pressure", in: 1013.25, want: "1013.25 hPa"},
+		{units: "pressure=mmHg", kind: "pressure", in: 1013.25, want: "760.00 mmHg"},
+		{units: "pressure=mmHg", kind: "vpd", in: 1.5, want: "1.500 kPa"},
+		{units: "pressure=mmHg,vpd=kPa", kind: "vpd", in: 1.5, want: "1.500 kPa"},
+		{units: "vpd=hPa", kind: "vpd", in: 1.5, want: "15.00 hPa"},
+		{units: "temperature=K", kind: "heat_index", in: 0, want: "273.15 K"},
+		{units: "battery=V", kind: "battery", in: 2950, want: "2.950 V"},
+		{units: "battery=%", kind: "battery", in: 2750, want: "50 %"},
+		{units: "battery=%", kind: "battery", in: 3100, want: "100 %"},
+		{units: "acceleration=m/s2", kind: "acceleration_z", in: 1, want: "9.807 m/s²"},
+		{units: "imperial", kind: "humidity", in: 45, want: "45.00 %"},
+		{units: "furlongs", wantErr: true},
+		{units: "temperature=R", wantErr: true},
+		{units: "loudness=dB", wantErr: true},
+	} {
+		r := httptest.NewRequest("GET", "/data.json?units="+url.QueryEscape(tc.units), nil)
+		units, err := dataUnits(r)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("dataUnits(%q) error = %v, want error: %v", tc.units, err, tc.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		u := units.Unit(tc.kind)
+		if got := u.Format(units.Convert(tc.kind, tc.in)) + " " + u.Symbol; got != tc.want {
+			t.Errorf("%s %v with units %q = %q, want %q", tc.kind, tc.in, tc.units, got, tc.want)
+		}
+	}
+}
@@ -22,56 +22,75 @@ async function refresh() {
     return;
   }
 
+  let units = encodeURIComponent(document.getElementById('units').value || "metric");
+
   let aliases = await fetch('/aliases.json').then(resp => { return resp.json() });
-  kinds().map((kind) => {
-    setGraphStaleness(kind, true);
-
-    return new Promise(async (_resolve, _error) => {
-      let resolution = Math.max(Math.floor(10 * duration / graph(kind).scrollWidth), 1);
-      let end_time_trunc = end_time - (end_time % duration);
-      Promise.all([
-        fetch(`/data.json?kind=${kind}&end_time=${end_time_trunc}&duration=${duration}&resolution=${resolution}`).then(resp => { return resp.json() }),
-        fetch(`/data.json?kind=${kind}&end_time=${end_time_trunc + duration}&duration=${duration}&resolution=${resolution}`).then(resp => { return resp.json() })
-      ]).then((data) => {
-        let names = {};
-        data = data.flat();
-
-        for (i in data) {
-          data[i]['ts'] = new Date(data[i]['ts']);
-          let ts = data[i]['ts'] / 1000;
-          if (ts < end_time - duration || ts > end_time) {
-            delete data[i];
-            continue
+  let visible = kinds();
+  if (visible.length == 0) {
+    return;
+  }
+  visible.map((kind) => { setGraphStaleness(kind, true) });
+
+  let resolution = Math.max(Math.floor(10 * duration / graph(visible[0]).scrollWidth), 1);
+  let end_time_trunc = end_time - (end_time % duration);
+  let query = `kind=${visible.join(',')}&duration=${duration}&resolution=${resolution}&units=${units}`;
+  Promise.all([
+    fetchData(`/data.json?${query}&end_time=${end_time_trunc}`),
+    fetchData(`/data.json?${query}&end_time=${end_time_trunc + duration}`)
+  ]).then((resps) => {
+    visible.map((kind) => {
+      let names = {};
+      let data = resps.map((resp) => { return resp.kinds[kind].points }).flat();
+      setUnit(kind, resps[0].kinds[kind].unit);
+
+      for (i in data) {
+        data[i]['ts'] = new Date(data[i]['ts']);
+        let ts = data[i]['ts'] / 1000;
+        if (ts < end_time - duration || ts > end_time) {
+          delete data[i];
+          continue
+        }
+
+        for (k in data[i]) {
+          if (k == 'ts') {
+            continue;
           }
 
-          for (k in data[i]) {
-            if (k == 'ts') {
-              continue;
-            }
-
-            let name = aliases[k] || k;
-            names[name] = true;
-            if (name != k) {
-              data[i][name] = data[i][k];
-              delete data[i][k];
-            }
+          let name = aliases[k] || k;
+          names[name] = true;
+          if (name != k) {
+            data[i][name] = data[i][k];
+            delete data[i][k];
           }
         }
+      }
 
-        plot(kind, data, Object.keys(names))
-        setGraphStaleness(kind, false);
-      });
-
+      plot(kind, data, Object.keys(names))
+      setGraphStaleness(kind, false);
     });
+  }).catch((error) => {
+    setError(error.message);
   });
 }
 
+async function fetchData(url) {
+  let resp = await fetch(url);
+  if (!resp.ok) {
+    throw new Error(await resp.text());
+  }
+  return resp.json();
+}
+
 function graph(kind) {
   return Array.from(document.getElementsByClassName("graph")).filter((graph) => { return graph.getAttribute("data-kind") == kind })[0]
 }
 
 function kinds() {
-  return Array.from(document.getElementsByClassName("graph")).map((graph) => { return graph.getAttribute("data-kind") })
+  return Array.from(document.getElementsByClassName("graph")).map((graph) => { return graph.getAttribute("data-kind") }).filter((kind) => { return !section(kind).hidden })
+}
+
+function section(kind) {
+  return Array.from(document.getElementsByTagName("section")).filter((section) => { return section.getAttribute("data-kind") == kind })[0]
 }
 
 function plot(kind, data, tags) {
@@ -107,6 +126,12 @@ function plot(kind, data, tags) {
   });
 }
 
+function setUnit(kind, unit) {
+  if (unit) {
+    section(kind).getElementsByClassName("unit")[0].innerText = unit.symbol;
+  }
+}
+
 function setError(error) {
   document.getElementById('error').innerText = error;
 }
@@ -147,6 +172,14 @@ function init() {
   Array.from(document.getElementsByClassName("graph")).map((graph) => {
     graph.id = "id" + Math.random().toString(16).slice(2);
   })
+  Array.from(document.querySelectorAll("#kinds input")).map((checkbox) => {
+    checkbox.addEventListener("change", function () {
+      section(checkbox.getAttribute("data-kind")).hidden = !checkbox.checked;
+      if (checkbox.checked) {
+        refresh();
+      }
+    })
+  });
   Array.from(document.getElementsByTagName("input")).map((input) => {
     input.addEventListener("keyup", function (event) {
       if (event.key === "Enter") {